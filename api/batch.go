@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/models"
+	"github.com/MultiX0/db-test/utils"
+)
+
+func ExecuteBatch(w http.ResponseWriter, r *http.Request) {
+	var batch models.BatchModel
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := functions.ExecuteBatch(batch)
+	if err != nil {
+		// point the client at the operation that rolled the batch back
+		var batchErr *functions.BatchError
+		if errors.As(err, &batchErr) {
+			status := http.StatusBadRequest
+			response := map[string]any{}
+			if constraintErr := functions.AsConstraintError(err); constraintErr != nil {
+				status, response = constraintResponse(constraintErr)
+			}
+
+			response["error"] = err.Error()
+			response["failed_operation"] = batchErr.Index
+			if batchErr.Ref != "" {
+				response["ref"] = batchErr.Ref
+			}
+			utils.WriteJSON(w, status, response)
+			return
+		}
+
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"results": results,
+	})
+}
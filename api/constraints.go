@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/utils"
+)
+
+// constraintResponse describes a refused write, 409 when the row clashes with an existing one and 422 when
+// the row itself breaks a check, not null or foreign key constraint
+func constraintResponse(constraintErr *functions.ConstraintError) (int, map[string]any) {
+	status := http.StatusUnprocessableEntity
+	if constraintErr.Type == functions.ConstraintUnique || constraintErr.Type == functions.ConstraintPrimaryKey {
+		status = http.StatusConflict
+	}
+
+	response := map[string]any{
+		"error":           constraintErr.Error(),
+		"constraint_type": constraintErr.Type,
+	}
+	if constraintErr.Constraint != "" {
+		response["constraint"] = constraintErr.Constraint
+	}
+	if constraintErr.Table != "" {
+		response["table"] = constraintErr.Table
+	}
+	if len(constraintErr.Columns) > 0 {
+		response["columns"] = constraintErr.Columns
+	}
+
+	return status, response
+}
+
+// respondConstraintError answers a write refused by a constraint and reports whether err was one
+func respondConstraintError(w http.ResponseWriter, err error) bool {
+	constraintErr := functions.AsConstraintError(err)
+	if constraintErr == nil {
+		return false
+	}
+
+	status, response := constraintResponse(constraintErr)
+	utils.WriteJSON(w, status, response)
+	return true
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestInsertReportsConstraintViolations(t *testing.T) {
+	openTestDB(t)
+	err := functions.CreateTable(models.TableModel{
+		Name:        "accounts",
+		KeyStrategy: "autoincrement",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "email", DataType: "txt", Unique: true},
+			{Name: "balance", DataType: "int"},
+		},
+		Checks: []models.CheckConstraintModel{{Name: "balance_positive", Expression: "balance >= 0"}},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	mustExec(t, "INSERT INTO accounts (email, balance) VALUES ('a@x.io', 0)")
+
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		kind       string
+		constraint string
+	}{
+		{"unique", `{"table":"accounts","columns":["email","balance"],"values":["a@x.io",1]}`, http.StatusConflict, functions.ConstraintUnique, "email_unique"},
+		{"check", `{"table":"accounts","columns":["email","balance"],"values":["b@x.io",-1]}`, http.StatusUnprocessableEntity, functions.ConstraintCheck, "balance_positive"},
+		{"bulk check", `{"table":"accounts","columns":["email","balance"],"rows":[["c@x.io",1],["d@x.io",-1]]}`, http.StatusUnprocessableEntity, functions.ConstraintCheck, "balance_positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(InsertIntoTable, http.MethodPost, "/v1/insert", tt.body, nil)
+			if response.Code != tt.status {
+				t.Fatalf("got status %d (%s), want %d", response.Code, response.Body, tt.status)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %s: %v", response.Body, err)
+			}
+			if body["constraint_type"] != tt.kind || body["constraint"] != tt.constraint {
+				t.Fatalf("got %v, want %s constraint %s", body, tt.kind, tt.constraint)
+			}
+		})
+	}
+}
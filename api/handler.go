@@ -48,6 +48,13 @@ func (w *wrappedWriter) WriteHeader(statusCode int) {
 	w.headerWritten = true
 }
 
+// Flush lets streamed responses push their rows through the logging middleware
+func (w *wrappedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func (s *APIServer) Run() error {
 	router := mux.NewRouter()
 	router.HandleFunc("/favicon.ico", serveFavicon).Methods("GET")
@@ -55,7 +62,15 @@ func (s *APIServer) Run() error {
 	// uncomment this when you start working on the client side routes like insert and other stuff
 	subrouter := router.PathPrefix("/v1").Subrouter()
 	subrouter.HandleFunc("/insert", InsertIntoTable).Methods("POST")
-	subrouter.HandleFunc("/select", SelectFromTable).Methods("GET")
+	subrouter.HandleFunc("/select", SelectFromTable).Methods("GET", "POST")
+	subrouter.HandleFunc("/tables/{table}", SelectTableRows).Methods("GET")
+	subrouter.HandleFunc("/tables/{table}", CreateRow).Methods("POST")
+	subrouter.HandleFunc("/tables/{table}/{id}", GetRow).Methods("GET")
+	subrouter.HandleFunc("/tables/{table}/{id}", PatchRow).Methods("PATCH")
+	subrouter.HandleFunc("/tables/{table}/{id}", DeleteRow).Methods("DELETE")
+	subrouter.HandleFunc("/update", UpdateTable).Methods("PATCH")
+	subrouter.HandleFunc("/delete", DeleteFromTable).Methods("DELETE")
+	subrouter.HandleFunc("/batch", ExecuteBatch).Methods("POST")
 
 	adminRoute := router.PathPrefix("/admin").Subrouter()
 
@@ -65,6 +80,14 @@ func (s *APIServer) Run() error {
 	adminRoute.HandleFunc("/tables", GetAllTables).Methods("GET")
 	adminRoute.HandleFunc("/table", GetTable).Methods("GET")
 	adminRoute.HandleFunc("/table", CreateTable).Methods("POST")
+	adminRoute.HandleFunc("/table", AlterTable).Methods("PATCH")
+	adminRoute.HandleFunc("/table", DropTable).Methods("DELETE")
+	adminRoute.HandleFunc("/table/rename", RenameTable).Methods("POST")
+	adminRoute.HandleFunc("/table/truncate", TruncateTable).Methods("POST")
+	adminRoute.HandleFunc("/table/duplicate", DuplicateTable).Methods("POST")
+	adminRoute.HandleFunc("/indexes", GetTableIndexes).Methods("GET")
+	adminRoute.HandleFunc("/index", CreateIndex).Methods("POST")
+	adminRoute.HandleFunc("/index", DropIndex).Methods("DELETE")
 	adminRoute.HandleFunc("/query", RowsAsJson).Methods("POST")
 	adminRoute.HandleFunc("/overview", GetOverview).Methods("GET")
 
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+)
+
+// openTestDB points the handlers at fresh main and admin databases in a temporary directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := sql.Open(dbclass.DriverName, filepath.Join(dir, "inline.db"))
+	if err != nil {
+		t.Fatalf("open main db: %v", err)
+	}
+	admin, err := sql.Open(dbclass.DriverName, filepath.Join(dir, "admin.db"))
+	if err != nil {
+		t.Fatalf("open admin db: %v", err)
+	}
+
+	previousDB, previousAdmin := dbclass.DB, dbclass.AdminDB
+	dbclass.DB, dbclass.AdminDB = db, admin
+	t.Cleanup(func() {
+		db.Close()
+		admin.Close()
+		dbclass.DB, dbclass.AdminDB = previousDB, previousAdmin
+	})
+
+	if err := dbclass.SetupAdminSchema(); err != nil {
+		t.Fatalf("setup admin schema: %v", err)
+	}
+}
+
+func mustExec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := dbclass.DB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// serve runs one request through a handler and returns the recorded response
+func serve(handler http.HandlerFunc, method string, target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	var request *http.Request
+	if body == "" {
+		request = httptest.NewRequest(method, target, nil)
+	} else {
+		request = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/models"
+	"github.com/MultiX0/db-test/utils"
+)
+
+func respondIndexError(w http.ResponseWriter, err error) {
+	if errors.Is(err, functions.ErrIndexNotFound) {
+		utils.RespondError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	respondTableError(w, err)
+}
+
+func GetTableIndexes(w http.ResponseWriter, r *http.Request) {
+	tableName := r.URL.Query().Get("table")
+	if err := functions.ValidateTableName(tableName); err != nil {
+		respondIndexError(w, err)
+		return
+	}
+
+	indexes, err := functions.GetTableIndexes(tableName)
+	if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, indexes)
+}
+
+func CreateIndex(w http.ResponseWriter, r *http.Request) {
+	var indexModel models.IndexModel
+	if err := json.NewDecoder(r.Body).Decode(&indexModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	index, err := functions.CreateIndex(indexModel)
+	if err != nil {
+		respondIndexError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, index)
+}
+
+func DropIndex(w http.ResponseWriter, r *http.Request) {
+	var dropModel models.DropIndexModel
+	if err := json.NewDecoder(r.Body).Decode(&dropModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := functions.DropIndex(dropModel.Name); err != nil {
+		respondIndexError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"index":   dropModel.Name,
+	})
+}
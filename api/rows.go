@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/utils"
+	"github.com/gorilla/mux"
+)
+
+// respondRowError answers 404 for missing tables and rows and describes constraint violations instead of a generic error
+func respondRowError(w http.ResponseWriter, err error) {
+	if respondConstraintError(w, err) {
+		return
+	}
+
+	if errors.Is(err, functions.ErrRowNotFound) || errors.Is(err, functions.ErrTableNotFound) {
+		utils.RespondError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	utils.RespondError(w, err.Error(), http.StatusBadRequest)
+}
+
+func GetRow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// only the select parameter applies to a single row, e.g. ?select=id,title,author(name)
+	parsed, err := functions.ParseSelectQuery(vars["table"], url.Values{"select": r.URL.Query()["select"]})
+	if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	row, err := functions.GetRowByKey(vars["table"], vars["id"], parsed.SelectedColumns)
+	if err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    row,
+	})
+}
+
+func CreateRow(w http.ResponseWriter, r *http.Request) {
+	var values map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tableName := mux.Vars(r)["table"]
+	result, err := functions.InsertRow(tableName, values)
+	if err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	row, err := functions.GetInsertedRow(tableName, result.ID)
+	if err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]any{
+		"message": "success",
+		"id":      result.ID,
+		"data":    row,
+	})
+}
+
+func PatchRow(w http.ResponseWriter, r *http.Request) {
+	var values map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	row, err := functions.UpdateRowByKey(vars["table"], vars["id"], values)
+	if err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    row,
+	})
+}
+
+func DeleteRow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	row, err := functions.DeleteRowByKey(vars["table"], vars["id"])
+	if err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"data":    row,
+	})
+}
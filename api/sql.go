@@ -20,6 +20,12 @@ func RowsAsJson(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := negotiateStream(r); format != "" && functions.IsSelectStatement(body.QUERY) {
+		sink := newRowSink(w, format)
+		respondStream(w, sink, functions.StreamQuery(r.Context(), body.QUERY, sink))
+		return
+	}
+
 	data, err := functions.RawSQL(body.QUERY)
 	if err != nil {
 		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
@@ -27,13 +33,8 @@ func RowsAsJson(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if bytes, ok := data.([]byte); ok {
-		var dataJson []map[string]any
-		err = json.Unmarshal(bytes, &dataJson)
-		if err != nil {
-			utils.RespondError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		utils.WriteJSON(w, http.StatusOK, map[string]any{"data": dataJson})
+		// the rows are already encoded, decoding them again would turn big integers into floats
+		utils.WriteJSON(w, http.StatusOK, map[string]any{"data": json.RawMessage(bytes)})
 	} else {
 		utils.WriteJSON(w, http.StatusOK, data)
 		return
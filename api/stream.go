@@ -0,0 +1,237 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/utils"
+)
+
+const (
+	streamNDJSON    = "application/x-ndjson"
+	streamCSV       = "text/csv"
+	streamJSONArray = "application/json"
+)
+
+// flushEvery is how many rows are written between flushes of a streamed response
+const flushEvery = 500
+
+// negotiateStream returns the streaming format asked for by the Accept header, or "" for the normal buffered response,
+// a chunked JSON array is asked for with "application/json; stream=true"
+func negotiateStream(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case streamNDJSON, streamCSV:
+			return mediaType
+		case streamJSONArray:
+			if params["stream"] == "true" {
+				return streamJSONArray
+			}
+		}
+	}
+
+	return ""
+}
+
+// streamSink is a RowSink that knows if the response status was already sent
+type streamSink interface {
+	functions.RowSink
+	started() bool
+}
+
+func newRowSink(w http.ResponseWriter, format string) streamSink {
+	switch format {
+	case streamCSV:
+		return &csvSink{w: w, writer: csv.NewWriter(w)}
+	case streamJSONArray:
+		return &jsonArraySink{w: w}
+	default:
+		return &ndjsonSink{w: w, encoder: json.NewEncoder(w)}
+	}
+}
+
+// respondStream writes the stream error as a normal response when nothing was sent yet, otherwise the
+// status line is gone and the truncated body is the only signal left, so the error is only logged
+func respondStream(w http.ResponseWriter, sink streamSink, err error) {
+	if err == nil {
+		return
+	}
+
+	if sink.started() {
+		log.Printf("stream aborted: %v", err)
+		if ndjson, ok := sink.(*ndjsonSink); ok {
+			ndjson.encoder.Encode(map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	utils.RespondError(w, err.Error(), http.StatusBadRequest)
+}
+
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+type ndjsonSink struct {
+	w       http.ResponseWriter
+	encoder *json.Encoder
+	columns []string
+	count   int
+}
+
+func (s *ndjsonSink) started() bool { return s.columns != nil }
+
+func (s *ndjsonSink) Start(columns []string) error {
+	s.columns = columns
+	s.w.Header().Set("Content-Type", streamNDJSON)
+	s.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *ndjsonSink) Row(values []any) error {
+	if err := s.encoder.Encode(rowObject(s.columns, values)); err != nil {
+		return err
+	}
+
+	s.count++
+	if s.count%flushEvery == 0 {
+		flush(s.w)
+	}
+	return nil
+}
+
+func (s *ndjsonSink) End() error {
+	flush(s.w)
+	return nil
+}
+
+type jsonArraySink struct {
+	w       http.ResponseWriter
+	columns []string
+	count   int
+}
+
+func (s *jsonArraySink) started() bool { return s.columns != nil }
+
+func (s *jsonArraySink) Start(columns []string) error {
+	s.columns = columns
+	s.w.Header().Set("Content-Type", streamJSONArray)
+	s.w.WriteHeader(http.StatusOK)
+	_, err := io.WriteString(s.w, "[")
+	return err
+}
+
+func (s *jsonArraySink) Row(values []any) error {
+	data, err := json.Marshal(rowObject(s.columns, values))
+	if err != nil {
+		return err
+	}
+
+	if s.count > 0 {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+
+	s.count++
+	if s.count%flushEvery == 0 {
+		flush(s.w)
+	}
+	return nil
+}
+
+func (s *jsonArraySink) End() error {
+	_, err := io.WriteString(s.w, "]\n")
+	flush(s.w)
+	return err
+}
+
+type csvSink struct {
+	w       http.ResponseWriter
+	writer  *csv.Writer
+	columns []string
+	count   int
+}
+
+func (s *csvSink) started() bool { return s.columns != nil }
+
+func (s *csvSink) Start(columns []string) error {
+	s.columns = columns
+	s.w.Header().Set("Content-Type", streamCSV+"; charset=utf-8")
+	s.w.WriteHeader(http.StatusOK)
+	return s.writer.Write(columns)
+}
+
+func (s *csvSink) Row(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = csvValue(value)
+	}
+
+	if err := s.writer.Write(record); err != nil {
+		return err
+	}
+
+	s.count++
+	if s.count%flushEvery == 0 {
+		s.writer.Flush()
+		flush(s.w)
+	}
+	return s.writer.Error()
+}
+
+func (s *csvSink) End() error {
+	s.writer.Flush()
+	flush(s.w)
+	return s.writer.Error()
+}
+
+func rowObject(columns []string, values []any) map[string]any {
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row
+}
+
+// csvValue writes NULL as an empty field, blobs as their encoded data and nested values as JSON
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case functions.BlobValue:
+		return v.Data
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateStream(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"application/json; stream=true", streamJSONArray},
+		{"application/x-ndjson", streamNDJSON},
+		{"text/csv; charset=utf-8", streamCSV},
+		{"text/html, text/csv;q=0.9", streamCSV},
+		{"not a media type;;, application/x-ndjson", streamNDJSON},
+	}
+
+	for _, tt := range tests {
+		request, _ := http.NewRequest(http.MethodGet, "/v1/select", nil)
+		request.Header.Set("Accept", tt.accept)
+		if got := negotiateStream(request); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func createStreamTable(t *testing.T) {
+	t.Helper()
+	mustExec(t, "CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT, size REAL, data BLOB, meta TEXT)")
+	mustExec(t, `INSERT INTO files (id, name, size, data, meta) VALUES
+		(1, 'a, "quoted"', 1.5, x'0102', '{"a":1}'),
+		(2, NULL, NULL, NULL, 'not json')`)
+}
+
+func TestStreamFormats(t *testing.T) {
+	openTestDB(t)
+	createStreamTable(t)
+
+	const body = `{"table":"files","columns":["id","name","size","data"],"order":[{"column":"id"}]}`
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "ndjson",
+			accept:      "application/x-ndjson",
+			contentType: streamNDJSON,
+			want: `{"data":{"$type":"blob","encoding":"base64","data":"AQI="},"id":1,"name":"a, \"quoted\"","size":1.5}` + "\n" +
+				`{"data":null,"id":2,"name":null,"size":null}` + "\n",
+		},
+		{
+			name:        "csv with nulls and blobs",
+			accept:      "text/csv",
+			contentType: "text/csv; charset=utf-8",
+			want:        "id,name,size,data\n1,\"a, \"\"quoted\"\"\",1.5,AQI=\n2,,,\n",
+		},
+		{
+			name:        "chunked json array",
+			accept:      "application/json; stream=true",
+			contentType: streamJSONArray,
+			want: `[{"data":{"$type":"blob","encoding":"base64","data":"AQI="},"id":1,"name":"a, \"quoted\"","size":1.5},` +
+				`{"data":null,"id":2,"name":null,"size":null}]` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(SelectFromTable, http.MethodPost, "/v1/select", body, map[string]string{"Accept": tt.accept})
+			if response.Code != http.StatusOK || response.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("got status %d with %q, want 200 with %q", response.Code, response.Header().Get("Content-Type"), tt.contentType)
+			}
+			if got := response.Body.String(); got != tt.want {
+				t.Fatalf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamQueryErrorBeforeTheFirstRow(t *testing.T) {
+	openTestDB(t)
+	createStreamTable(t)
+
+	// the filter only fails once SQLite reads the malformed json of row 2, after the statement started
+	const body = `{"table":"files","columns":["id","name"],"where":{"column":"meta","path":"$.a","operator":"eq","value":5},"order":[{"column":"id","direction":"desc"}]}`
+
+	for _, accept := range []string{"application/x-ndjson", "text/csv", "application/json; stream=true"} {
+		t.Run(accept, func(t *testing.T) {
+			response := serve(SelectFromTable, http.MethodPost, "/v1/select", body, map[string]string{"Accept": accept})
+			if response.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d with %q, want 400", response.Code, response.Body)
+			}
+
+			var errorBody map[string]any
+			if err := json.Unmarshal(response.Body.Bytes(), &errorBody); err != nil || !strings.Contains(response.Body.String(), "malformed JSON") {
+				t.Fatalf("got body %q, want only the json error", response.Body)
+			}
+		})
+	}
+}
+
+func TestStreamWithoutRows(t *testing.T) {
+	openTestDB(t)
+	createStreamTable(t)
+
+	const body = `{"table":"files","columns":["id","name"],"where":{"column":"id","operator":"eq","value":99}}`
+
+	tests := map[string]string{
+		"application/x-ndjson":          "",
+		"text/csv":                      "id,name\n",
+		"application/json; stream=true": "[]\n",
+	}
+
+	for accept, want := range tests {
+		t.Run(accept, func(t *testing.T) {
+			response := serve(SelectFromTable, http.MethodPost, "/v1/select", body, map[string]string{"Accept": accept})
+			if response.Code != http.StatusOK || response.Body.String() != want {
+				t.Fatalf("got status %d with %q, want 200 with %q", response.Code, response.Body, want)
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/MultiX0/db-test/functions"
 	"github.com/MultiX0/db-test/models"
 	"github.com/MultiX0/db-test/utils"
+	"github.com/gorilla/mux"
 )
 
 func GetAllTables(w http.ResponseWriter, r *http.Request) {
@@ -60,6 +63,116 @@ func CreateTable(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func AlterTable(w http.ResponseWriter, r *http.Request) {
+	var alterModel models.AlterTableModel
+	if err := json.NewDecoder(r.Body).Decode(&alterModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := functions.AlterTable(alterModel); err != nil {
+		respondRowError(w, err)
+		return
+	}
+
+	table, err := functions.GetTableData(alterModel.TableName)
+	if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, table)
+}
+
+// respondTableError answers 404 for missing tables and 428 for destructive actions that were not confirmed
+func respondTableError(w http.ResponseWriter, err error) {
+	if errors.Is(err, functions.ErrNotConfirmed) {
+		utils.RespondError(w, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	respondRowError(w, err)
+}
+
+func DropTable(w http.ResponseWriter, r *http.Request) {
+	var dropModel models.DropTableModel
+	if err := json.NewDecoder(r.Body).Decode(&dropModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := functions.DropTable(dropModel); err != nil {
+		respondTableError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message": "success",
+		"table":   dropModel.TableName,
+	})
+}
+
+func RenameTable(w http.ResponseWriter, r *http.Request) {
+	var renameModel models.RenameTableModel
+	if err := json.NewDecoder(r.Body).Decode(&renameModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := functions.RenameTable(renameModel); err != nil {
+		respondTableError(w, err)
+		return
+	}
+
+	table, err := functions.GetTableData(renameModel.NewName)
+	if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, table)
+}
+
+func TruncateTable(w http.ResponseWriter, r *http.Request) {
+	var truncateModel models.TruncateTableModel
+	if err := json.NewDecoder(r.Body).Decode(&truncateModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	affected, err := functions.TruncateTable(truncateModel)
+	if err != nil {
+		respondTableError(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"message":       "success",
+		"affected_rows": affected,
+	})
+}
+
+func DuplicateTable(w http.ResponseWriter, r *http.Request) {
+	var duplicateModel models.DuplicateTableModel
+	if err := json.NewDecoder(r.Body).Decode(&duplicateModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := functions.DuplicateTable(duplicateModel); err != nil {
+		respondTableError(w, err)
+		return
+	}
+
+	table, err := functions.GetTableData(duplicateModel.NewName)
+	if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, table)
+}
+
 func InsertIntoTable(w http.ResponseWriter, r *http.Request) {
 	var insertModel models.InsertModel
 	if err := json.NewDecoder(r.Body).Decode(&insertModel); err != nil {
@@ -67,42 +180,153 @@ func InsertIntoTable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := functions.InsertIntoTable(insertModel)
+	if len(insertModel.Rows) > 0 {
+		result, err := functions.BulkInsertIntoTable(insertModel)
+		if err != nil {
+			if respondConstraintError(w, err) {
+				return
+			}
+			utils.RespondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, map[string]any{
+			"msg":     "success",
+			"ids":     result.IDs,
+			"results": result.Results,
+			"errors":  result.Errors,
+		})
+		return
+	}
+
+	result, err := functions.InsertIntoTable(insertModel)
 	if err != nil {
+		if respondConstraintError(w, err) {
+			return
+		}
 		utils.RespondError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]any{
-		"msg": "success",
-		"id":  id,
+		"msg":    "success",
+		"id":     result.ID,
+		"status": result.Status,
 	})
 
 }
 
+// SelectFromTable accepts the JSON body form, a GET without a body is read from the query string instead
 func SelectFromTable(w http.ResponseWriter, r *http.Request) {
 	var selectModel models.SelectModel
-	if err := json.NewDecoder(r.Body).Decode(&selectModel); err != nil {
+	err := json.NewDecoder(r.Body).Decode(&selectModel)
+	if err == io.EOF && r.Method == http.MethodGet {
+		parsed, err := functions.ParseSelectQuery(r.URL.Query().Get("table"), r.URL.Query())
+		if err != nil {
+			utils.RespondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		selectModel = *parsed
+	} else if err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSelectResponse(w, r, selectModel)
+}
+
+// SelectTableRows handles PostgREST style reads like /v1/tables/users?select=id,name&age=gte.18&order=created_at.desc&limit=20
+func SelectTableRows(w http.ResponseWriter, r *http.Request) {
+	selectModel, err := functions.ParseSelectQuery(mux.Vars(r)["table"], r.URL.Query())
+	if err != nil {
 		utils.RespondError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	results, err := functions.SelectFromTable(selectModel)
+	writeSelectResponse(w, r, *selectModel)
+}
+
+func writeSelectResponse(w http.ResponseWriter, r *http.Request, selectModel models.SelectModel) {
+	if format := negotiateStream(r); format != "" {
+		sink := newRowSink(w, format)
+		respondStream(w, sink, functions.StreamSelect(r.Context(), selectModel, sink))
+		return
+	}
+
+	results, nextCursor, err := functions.SelectFromTable(selectModel)
 	if err != nil {
 		utils.RespondError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var resultsMap []map[string]any
-	err = json.Unmarshal(results, &resultsMap)
+	// the rows are already encoded, decoding them again would turn big integers into floats
+	response := map[string]any{
+		"message":     "success",
+		"data":        json.RawMessage(results),
+		"next_cursor": nil,
+	}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+
+	// GET reads carry an ETag so browsers and proxies can revalidate instead of downloading the rows again
+	if r.Method == http.MethodGet {
+		utils.WriteJSONWithETag(w, r, http.StatusOK, response)
+		return
+	}
 
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func UpdateTable(w http.ResponseWriter, r *http.Request) {
+	var updateModel models.UpdateModel
+	if err := json.NewDecoder(r.Body).Decode(&updateModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	affected, err := functions.UpdateTable(updateModel)
 	if err != nil {
+		if respondConstraintError(w, err) {
+			return
+		}
 		utils.RespondError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]any{
-		"message": "success",
-		"data":    resultsMap,
+		"message":       "success",
+		"affected_rows": affected,
 	})
 }
+
+func DeleteFromTable(w http.ResponseWriter, r *http.Request) {
+	var deleteModel models.DeleteModel
+	if err := json.NewDecoder(r.Body).Decode(&deleteModel); err != nil {
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	affected, deleted, err := functions.DeleteFromTable(deleteModel)
+	if err != nil {
+		if respondConstraintError(w, err) {
+			return
+		}
+		utils.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]any{
+		"message":       "success",
+		"affected_rows": affected,
+	}
+
+	if deleteModel.Returning {
+		if deleted == nil {
+			deleted = []map[string]any{}
+		}
+		response["data"] = deleted
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
+}
@@ -11,4 +11,7 @@ var DataTypes = map[string]string{
 	"datetime":  "DATETIME",
 	"time":      "TIME",
 	"timestamp": "TIMESTAMP",
+	"uuid":      "TEXT", // checked to be a 36 character uuid, see CreateTable
+	"enum":      "TEXT", // limited to the enum_values of the column, see CreateTable
+	"json":      "TEXT", // checked with json_valid, TEXT affinity keeps numbers inside the JSON text as they were sent
 }
@@ -1,11 +1,30 @@
 package dbclass
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/MultiX0/db-test/models"
 )
 
+// TableMetadata is what admin.db knows about a table of the main database
+type TableMetadata struct {
+	Name        string
+	Description string
+	KeyStrategy string
+	SchemaSQL   string // the CREATE TABLE statement the metadata was recorded for
+	CreatedAt   string
+	Columns     map[string]ColumnMetadata
+}
+
+type ColumnMetadata struct {
+	Description string
+	DataType    string   // the logical type, e.g. uuid, or the SQLite type for plain columns
+	StorageType string   // the declared SQLite type
+	EnumValues  []string // the allowed values of an enum column
+}
+
 func SetupAdminSchema() error {
 	err := CreateTableSchema()
 	if err != nil {
@@ -22,19 +41,224 @@ func SetupAdminSchema() error {
 }
 
 func CreateTableSchema() error {
-	sqlstmt := "CREATE TABLE IF NOT EXISTS tables ( name TEXT PRIMARY KEY, description TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP );"
+	sqlstmt := "CREATE TABLE IF NOT EXISTS tables ( name TEXT PRIMARY KEY, description TEXT, key_strategy TEXT, schema_sql TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP );"
 	_, err := AdminDB.Exec(sqlstmt)
+	if err != nil {
+		return err
+	}
+
+	// admin databases created by older versions miss the newer columns
+	if err := addColumnIfMissing("tables", "key_strategy", "TEXT"); err != nil {
+		return err
+	}
+	return addColumnIfMissing("tables", "schema_sql", "TEXT")
+}
+
+// addColumnIfMissing migrates an existing admin table by adding a column it does not have yet
+func addColumnIfMissing(table string, column string, definition string) error {
+	var count int
+	err := AdminDB.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = AdminDB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
+
 func CreateColumnsSchema() error {
-	sqlstmt := "CREATE TABLE IF NOT EXISTS columns ( id INTEGER PRIMARY KEY AUTOINCREMENT, table_name TEXT NOT NULL, is_pk BOOLEAN NOT NULL DEFAULT 0, null_able BOOLEAN NOT NULL DEFAULT 1, date_type TEXT NOT NULL, original_type TEXT NOT NULL, FOREIGN KEY (table_name) REFERENCES tables(name) ON DELETE CASCADE ON UPDATE CASCADE );"
+	sqlstmt := "CREATE TABLE IF NOT EXISTS columns ( id INTEGER PRIMARY KEY AUTOINCREMENT, table_name TEXT NOT NULL, name TEXT NOT NULL DEFAULT '', description TEXT, is_pk BOOLEAN NOT NULL DEFAULT 0, null_able BOOLEAN NOT NULL DEFAULT 1, date_type TEXT NOT NULL, original_type TEXT NOT NULL, FOREIGN KEY (table_name) REFERENCES tables(name) ON DELETE CASCADE ON UPDATE CASCADE );"
 	_, err := AdminDB.Exec(sqlstmt)
+	if err != nil {
+		return err
+	}
+
+	if err := addColumnIfMissing("columns", "name", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("columns", "description", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("columns", "enum_values", "TEXT"); err != nil {
+		return err
+	}
+
+	_, err = AdminDB.Exec("CREATE INDEX IF NOT EXISTS idx_columns_table_name ON columns (table_name)")
 	return err
+}
 
+// InsertTable records a table or updates its metadata, the creation time of a known table is kept
+func InsertTable(table models.TableModel) error {
+	_, err := AdminDB.Exec(`INSERT INTO tables (name, description, key_strategy, schema_sql) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET description = excluded.description, key_strategy = excluded.key_strategy, schema_sql = excluded.schema_sql`,
+		table.Name, table.Description, table.KeyStrategy, table.Sql)
+	return err
 }
 
-func InsertTable(table models.TableModel) {
+// InsertColumns replaces the recorded columns of a table
+func InsertColumns(tableName string, columns []models.ColumnModel) error {
+	tx, err := AdminDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM columns WHERE table_name = ?", tableName)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO columns (table_name, name, description, is_pk, null_able, date_type, original_type, enum_values) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, column := range columns {
+		dataType := column.LogicalType
+		if dataType == "" {
+			dataType = column.DataType
+		}
+
+		// the allowed values of an enum column are kept as a JSON array
+		var enumValues *string
+		if len(column.EnumValues) > 0 {
+			encoded, err := json.Marshal(column.EnumValues)
+			if err != nil {
+				return err
+			}
+			text := string(encoded)
+			enumValues = &text
+		}
 
+		_, err = stmt.Exec(tableName, column.Name, column.Description, column.IsPrimaryKey, column.Nullable, dataType, column.DataType, enumValues)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func InsertColumns(columns []models.ColumnModel) {}
+// GetTableMetadata returns the recorded metadata of a table, nil when the table was never recorded
+func GetTableMetadata(tableName string) (*TableMetadata, error) {
+	var description, keyStrategy, schemaSQL, createdAt sql.NullString
+	err := AdminDB.QueryRow("SELECT description, key_strategy, schema_sql, created_at FROM tables WHERE name = ?", tableName).
+		Scan(&description, &keyStrategy, &schemaSQL, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &TableMetadata{
+		Name:        tableName,
+		Description: description.String,
+		KeyStrategy: keyStrategy.String,
+		SchemaSQL:   schemaSQL.String,
+		CreatedAt:   createdAt.String,
+		Columns:     make(map[string]ColumnMetadata),
+	}
+
+	rows, err := AdminDB.Query("SELECT name, description, date_type, original_type, enum_values FROM columns WHERE table_name = ?", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var columnDescription, enumValues sql.NullString
+		var column ColumnMetadata
+		if err := rows.Scan(&name, &columnDescription, &column.DataType, &column.StorageType, &enumValues); err != nil {
+			return nil, err
+		}
+		column.Description = columnDescription.String
+
+		if enumValues.Valid {
+			if err := json.Unmarshal([]byte(enumValues.String), &column.EnumValues); err != nil {
+				return nil, fmt.Errorf("invalid enum values recorded for column %s: %v", name, err)
+			}
+		}
+		metadata.Columns[name] = column
+	}
+
+	return metadata, rows.Err()
+}
+
+// GetRecordedTables returns the names of every table recorded in admin.db
+func GetRecordedTables() ([]string, error) {
+	rows, err := AdminDB.Query("SELECT name FROM tables")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// DeleteTableMetadata removes a table and its columns from admin.db
+func DeleteTableMetadata(tableName string) error {
+	tx, err := AdminDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the columns are removed explicitly, the cascade only runs when foreign keys are enforced
+	if _, err := tx.Exec("DELETE FROM columns WHERE table_name = ?", tableName); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tables WHERE name = ?", tableName); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RenameTableMetadata moves the recorded metadata of a table and its columns to a new table name
+func RenameTableMetadata(tableName string, newName string) error {
+	tx, err := AdminDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the table goes first so an enforced ON UPDATE CASCADE never sees columns without their table
+	if _, err := tx.Exec("UPDATE tables SET name = ? WHERE name = ?", newName, tableName); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE columns SET table_name = ? WHERE table_name = ?", newName, tableName); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTableKeyStrategy returns the recorded primary key strategy of a table, empty when nothing was recorded
+func GetTableKeyStrategy(tableName string) (string, error) {
+	var strategy sql.NullString
+	err := AdminDB.QueryRow("SELECT key_strategy FROM tables WHERE name = ?", tableName).Scan(&strategy)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strategy.String, nil
+}
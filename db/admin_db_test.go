@@ -0,0 +1,86 @@
+package dbclass
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+// openTestAdminDB points AdminDB at a fresh database in a temporary directory
+func openTestAdminDB(t *testing.T) {
+	t.Helper()
+
+	admin, err := sql.Open(DriverName, filepath.Join(t.TempDir(), "admin.db"))
+	if err != nil {
+		t.Fatalf("open admin db: %v", err)
+	}
+
+	previous := AdminDB
+	AdminDB = admin
+	t.Cleanup(func() {
+		admin.Close()
+		AdminDB = previous
+	})
+}
+
+func TestSetupAdminSchemaMigratesOlderDatabases(t *testing.T) {
+	openTestAdminDB(t)
+
+	// the admin schema before key strategies, recorded schemas, column names, descriptions and enum values
+	for _, statement := range []string{
+		"CREATE TABLE tables ( name TEXT PRIMARY KEY, description TEXT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP )",
+		"CREATE TABLE columns ( id INTEGER PRIMARY KEY AUTOINCREMENT, table_name TEXT NOT NULL, is_pk BOOLEAN NOT NULL DEFAULT 0, null_able BOOLEAN NOT NULL DEFAULT 1, date_type TEXT NOT NULL, original_type TEXT NOT NULL, FOREIGN KEY (table_name) REFERENCES tables(name) ON DELETE CASCADE ON UPDATE CASCADE )",
+		"INSERT INTO tables (name, description, created_at) VALUES ('legacy', 'kept', '2020-01-01 00:00:00')",
+		"INSERT INTO columns (table_name, date_type, original_type) VALUES ('legacy', 'TEXT', 'TEXT')",
+	} {
+		if _, err := AdminDB.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	// running the setup twice checks that the migration is idempotent
+	for i := 0; i < 2; i++ {
+		if err := SetupAdminSchema(); err != nil {
+			t.Fatalf("setup admin schema: %v", err)
+		}
+	}
+
+	metadata, err := GetTableMetadata("legacy")
+	if err != nil {
+		t.Fatalf("get legacy metadata: %v", err)
+	}
+	if metadata.Description != "kept" || metadata.KeyStrategy != "" || metadata.SchemaSQL != "" {
+		t.Fatalf("got %+v, want the old row with empty new fields", metadata)
+	}
+
+	err = InsertTable(models.TableModel{Name: "legacy", Description: "updated", KeyStrategy: "client", Sql: "CREATE TABLE legacy (code TEXT PRIMARY KEY)"})
+	if err != nil {
+		t.Fatalf("insert table: %v", err)
+	}
+	err = InsertColumns("legacy", []models.ColumnModel{
+		{Name: "code", DataType: "TEXT", IsPrimaryKey: true, Description: "the code"},
+		{Name: "state", DataType: "TEXT", LogicalType: "enum", EnumValues: []string{"a", "b"}},
+	})
+	if err != nil {
+		t.Fatalf("insert columns: %v", err)
+	}
+
+	metadata, err = GetTableMetadata("legacy")
+	if err != nil {
+		t.Fatalf("get legacy metadata: %v", err)
+	}
+	if metadata.Description != "updated" || metadata.KeyStrategy != "client" || metadata.CreatedAt != "2020-01-01T00:00:00Z" {
+		t.Fatalf("got %+v, want the new fields recorded and the creation time kept", metadata)
+	}
+
+	want := map[string]ColumnMetadata{
+		"code":  {Description: "the code", DataType: "TEXT", StorageType: "TEXT"},
+		"state": {DataType: "enum", StorageType: "TEXT", EnumValues: []string{"a", "b"}},
+	}
+	if !reflect.DeepEqual(metadata.Columns, want) {
+		t.Fatalf("got columns %+v, want %+v", metadata.Columns, want)
+	}
+}
@@ -2,22 +2,70 @@ package dbclass
 
 import (
 	"database/sql"
+	"fmt"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// DriverName is the sqlite3 driver with the InlineDB custom functions (REGEXP) registered and foreign keys
+// enforced on every connection
+const DriverName = "sqlite3_inline"
+
 var (
 	DB      *sql.DB
 	AdminDB *sql.DB
 )
 
+// Querier is the subset of methods shared by *sql.DB and *sql.Tx, so the same code can run inside or outside a transaction
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+var regexps = newRegexpCache(regexpCacheSize)
+
+func init() {
+	sql.Register(DriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// SQLite leaves foreign keys unenforced unless every connection asks for it
+			if _, err := conn.Exec("PRAGMA foreign_keys = ON", nil); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("regexp", regexpMatch, true)
+		},
+	})
+}
+
+// regexpMatch implements "value REGEXP pattern", sqlite calls it as regexp(pattern, value)
+func regexpMatch(pattern string, value any) (bool, error) {
+	if value == nil {
+		return false, nil
+	}
+
+	re, err := regexps.compile(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return re.MatchString(v), nil
+	case []byte:
+		return re.Match(v), nil
+	default:
+		return re.MatchString(fmt.Sprint(v)), nil
+	}
+}
+
 func InitDB() error {
-	db, err := sql.Open("sqlite3", "./inline.db")
+	db, err := sql.Open(DriverName, "./inline.db")
 	if err != nil {
 		return err
 	}
 
-	admin, err := sql.Open("sqlite3", "./admin.db")
+	admin, err := sql.Open(DriverName, "./admin.db")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,72 @@
+package dbclass
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+)
+
+// regexpCacheSize bounds the compiled patterns kept in memory, the patterns come from client filters
+const regexpCacheSize = 128
+
+// regexpCache keeps the most recently used compiled patterns since REGEXP is called once per row
+type regexpCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is the most recently used pattern
+	entries  map[string]*list.Element
+}
+
+type regexpEntry struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newRegexpCache(capacity int) *regexpCache {
+	return &regexpCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// compile returns the compiled pattern, the least recently used one is evicted once the cache is full
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*regexpEntry).re, nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another connection may have compiled the same pattern meanwhile
+	if element, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*regexpEntry).re, nil
+	}
+
+	c.entries[pattern] = c.order.PushFront(&regexpEntry{pattern: pattern, re: re})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexpEntry).pattern)
+	}
+
+	return re, nil
+}
+
+// len returns how many patterns are cached
+func (c *regexpCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,63 @@
+package dbclass
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegexpCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newRegexpCache(2)
+
+	first, err := cache.compile("^a")
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if _, err := cache.compile("^b"); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	// using ^a again makes ^b the least recently used pattern
+	again, _ := cache.compile("^a")
+	if again != first {
+		t.Fatalf("expected the cached pattern to be reused")
+	}
+
+	if _, err := cache.compile("^c"); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	if cache.len() != 2 {
+		t.Fatalf("cache holds %d patterns, want 2", cache.len())
+	}
+	if _, ok := cache.entries["^b"]; ok {
+		t.Fatalf("expected ^b to be evicted")
+	}
+	if _, ok := cache.entries["^a"]; !ok {
+		t.Fatalf("expected ^a to be kept")
+	}
+}
+
+func TestRegexpCacheStaysBounded(t *testing.T) {
+	cache := newRegexpCache(regexpCacheSize)
+
+	for i := 0; i < regexpCacheSize*3; i++ {
+		if _, err := cache.compile(fmt.Sprintf("^%d$", i)); err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+	}
+
+	if cache.len() != regexpCacheSize {
+		t.Fatalf("cache holds %d patterns, want %d", cache.len(), regexpCacheSize)
+	}
+}
+
+func TestRegexpCacheRejectsInvalidPatterns(t *testing.T) {
+	cache := newRegexpCache(2)
+
+	if _, err := cache.compile("("); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+	if cache.len() != 0 {
+		t.Fatalf("invalid patterns should not be cached")
+	}
+}
@@ -0,0 +1,267 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+var aggregateFunctions = map[string]string{
+	"count": "COUNT",
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+}
+
+// bucketFormatPattern accepts strftime formats made of substitutions and separators, which keeps quotes out
+// of the format so it can be written into the statement as a literal
+var bucketFormatPattern = regexp.MustCompile(`^(%[YmdHMSjwWfsJ%]|[ :/T._-])+$`)
+
+// IsAggregateSelect reports if the select groups rows instead of returning them one by one
+func IsAggregateSelect(selectModel models.SelectModel) bool {
+	return len(selectModel.Aggregates) > 0 || len(selectModel.GroupBy) > 0 || len(selectModel.Buckets) > 0
+}
+
+// buildAggregateQuery builds a grouped select like "count per status" or "sum of amount by day", the selected
+// columns default to the group_by columns and the bucket aliases and every output column can be used in order and having
+func buildAggregateQuery(selectModel models.SelectModel, whereClause string, params []any) (*selectQuery, error) {
+	if selectModel.Cursor != "" {
+		return nil, fmt.Errorf("cursor pagination is not supported for aggregate selects, use offset instead")
+	}
+
+	for _, column := range selectModel.GroupBy {
+		if strings.TrimSpace(column) == "*" {
+			return nil, fmt.Errorf("cannot group by '*'")
+		}
+	}
+
+	if err := ValidateColumns(selectModel.TableName, selectModel.GroupBy); err != nil {
+		return nil, err
+	}
+
+	groupSet := make(map[string]bool)
+	for _, column := range selectModel.GroupBy {
+		groupSet[column] = true
+	}
+
+	// aliasExprs holds the expression behind every bucket and aggregate alias
+	aliasExprs := make(map[string]string)
+	var groupExprs []string
+	var bucketAliases []string
+	for _, bucket := range selectModel.Buckets {
+		alias, expr, err := buildBucketExpr(selectModel.TableName, bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		if groupSet[alias] || aliasExprs[alias] != "" {
+			return nil, fmt.Errorf("duplicate output column '%s', give the bucket a different alias", alias)
+		}
+		aliasExprs[alias] = expr
+		groupExprs = append(groupExprs, expr)
+		bucketAliases = append(bucketAliases, alias)
+	}
+
+	selectedColumns := selectModel.SelectedColumns
+	if len(selectedColumns) == 0 {
+		selectedColumns = append(append([]string{}, selectModel.GroupBy...), bucketAliases...)
+	}
+
+	outputs := make(map[string]bool)
+	var selected []string
+	for _, column := range selectedColumns {
+		expr, isBucket := aliasExprs[column]
+		if !groupSet[column] && !isBucket {
+			return nil, fmt.Errorf("column '%s' should be part of group_by or a bucket alias to be selected with aggregates", column)
+		}
+		if outputs[column] {
+			continue
+		}
+		outputs[column] = true
+		if isBucket {
+			selected = append(selected, fmt.Sprintf("%s AS %s", expr, column))
+		} else {
+			selected = append(selected, column)
+		}
+	}
+
+	for _, aggregate := range selectModel.Aggregates {
+		alias, expr, err := buildAggregateExpr(selectModel.TableName, aggregate)
+		if err != nil {
+			return nil, err
+		}
+
+		if outputs[alias] || aliasExprs[alias] != "" {
+			return nil, fmt.Errorf("duplicate output column '%s', give the aggregate a different alias", alias)
+		}
+		outputs[alias] = true
+		aliasExprs[alias] = expr
+		selected = append(selected, fmt.Sprintf("%s AS %s", expr, alias))
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no columns specified")
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selected, ", "), selectModel.TableName)
+
+	if whereClause != "" {
+		query += " WHERE " + whereClause
+	}
+
+	groupExprs = append(append([]string{}, selectModel.GroupBy...), groupExprs...)
+	if len(groupExprs) > 0 {
+		query += " GROUP BY " + strings.Join(groupExprs, ", ")
+	}
+
+	if selectModel.Having != nil {
+		havingClause, havingParams, err := buildExpressionNode(*selectModel.Having, 1, func(condition models.FilterCondition) (string, []any, error) {
+			return buildHavingCondition(groupSet, aliasExprs, condition)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		query += " HAVING " + havingClause
+		params = append(params, havingParams...)
+	}
+
+	var orderParts []string
+	for _, item := range selectModel.Order {
+		column := strings.TrimSpace(item.Column)
+		if !outputs[column] {
+			return nil, fmt.Errorf("aggregate selects can only be ordered by the selected columns, bucket aliases or aggregate aliases, got '%s'", column)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(item.Direction)) {
+		case "", "asc":
+			orderParts = append(orderParts, column+" ASC")
+		case "desc":
+			orderParts = append(orderParts, column+" DESC")
+		default:
+			return nil, fmt.Errorf("invalid order direction: %s", item.Direction)
+		}
+	}
+
+	// the group_by columns and buckets identify each result row, so they make the page order stable
+	for _, expr := range groupExprs {
+		orderParts = append(orderParts, expr+" ASC")
+	}
+
+	if len(orderParts) > 0 {
+		query += " ORDER BY " + strings.Join(orderParts, ", ")
+	}
+
+	return &selectQuery{Query: query, Params: params}, nil
+}
+
+// buildAggregateExpr validates one aggregate and returns its output alias and SQL expression
+func buildAggregateExpr(tableName string, aggregate models.AggregateModel) (string, string, error) {
+	function := strings.ToLower(strings.TrimSpace(aggregate.Function))
+	sqlFunction, ok := aggregateFunctions[function]
+	if !ok {
+		return "", "", fmt.Errorf("invalid aggregate function: %s", aggregate.Function)
+	}
+
+	column := strings.TrimSpace(aggregate.Column)
+	if column == "" {
+		column = "*"
+	}
+
+	if column == "*" {
+		if function != "count" {
+			return "", "", fmt.Errorf("only count can be used with '*'")
+		}
+		if aggregate.Distinct {
+			return "", "", fmt.Errorf("count distinct requires a column")
+		}
+	} else if err := ValidateColumns(tableName, []string{column}); err != nil {
+		return "", "", err
+	}
+
+	alias := strings.TrimSpace(aggregate.Alias)
+	if alias == "" {
+		alias = function
+		if column != "*" {
+			alias = function + "_" + column
+		}
+	}
+
+	if !isValidAlias(alias) {
+		return "", "", fmt.Errorf("invalid aggregate alias: %s", alias)
+	}
+
+	argument := column
+	if aggregate.Distinct {
+		argument = "DISTINCT " + column
+	}
+
+	return alias, fmt.Sprintf("%s(%s)", sqlFunction, argument), nil
+}
+
+// buildBucketExpr validates one bucket and returns its output alias and SQL expression
+func buildBucketExpr(tableName string, bucket models.BucketModel) (string, string, error) {
+	column := strings.TrimSpace(bucket.Column)
+	if column == "" || column == "*" {
+		return "", "", fmt.Errorf("bucket requires a column")
+	}
+	if err := ValidateColumns(tableName, []string{column}); err != nil {
+		return "", "", err
+	}
+
+	function := strings.ToLower(strings.TrimSpace(bucket.Function))
+	var expr string
+	switch function {
+	case "date":
+		if bucket.Format != "" {
+			return "", "", fmt.Errorf("format is only used by strftime buckets")
+		}
+		expr = fmt.Sprintf("date(%s)", column)
+	case "strftime":
+		if !bucketFormatPattern.MatchString(bucket.Format) {
+			return "", "", fmt.Errorf("invalid strftime format: %s, use substitutions like %%Y-%%m", bucket.Format)
+		}
+		expr = fmt.Sprintf("strftime('%s', %s)", bucket.Format, column)
+	default:
+		return "", "", fmt.Errorf("invalid bucket function: %s, use date or strftime", bucket.Function)
+	}
+
+	alias := strings.TrimSpace(bucket.Alias)
+	if alias == "" {
+		alias = function + "_" + column
+	}
+	if !isValidAlias(alias) {
+		return "", "", fmt.Errorf("invalid bucket alias: %s", alias)
+	}
+
+	return alias, expr, nil
+}
+
+// isValidAlias checks the name of an output column, the prefixes of the hidden columns are reserved
+func isValidAlias(alias string) bool {
+	return ValidateColumnName(alias) == nil && alias != "*" && !isHiddenColumn(alias)
+}
+
+// buildHavingCondition resolves a having leaf against the aggregate and bucket aliases and the group_by columns
+func buildHavingCondition(groupSet map[string]bool, aliasExprs map[string]string, condition models.FilterCondition) (string, []any, error) {
+	if err := ValidateOperator(condition.Operator); err != nil {
+		return "", nil, err
+	}
+
+	if condition.Path != "" {
+		return "", nil, fmt.Errorf("json path is not supported in having conditions")
+	}
+
+	if expr, ok := aliasExprs[condition.Column]; ok {
+		return buildCondition(expr, nil, condition.Operator, condition.Value)
+	}
+
+	if groupSet[condition.Column] {
+		return buildCondition(condition.Column, nil, condition.Operator, condition.Value)
+	}
+
+	return "", nil, fmt.Errorf("having column '%s' should be an aggregate alias, a bucket alias or a group_by column", condition.Column)
+}
@@ -0,0 +1,142 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func createOrdersTable(t *testing.T) {
+	t.Helper()
+	mustExec(t, "CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT, amount REAL, created_at DATETIME)")
+	mustExec(t, `INSERT INTO orders (id, status, amount, created_at) VALUES
+		(1, 'paid', 10, '2024-01-01 09:00:00'),
+		(2, 'paid', 5.5, '2024-01-01 18:30:00'),
+		(3, 'open', 7, '2024-01-02 08:00:00'),
+		(4, 'paid', 2, '2024-02-10 12:00:00'),
+		(5, 'void', 1, '2024-02-11 12:00:00')`)
+}
+
+func TestAggregateSelects(t *testing.T) {
+	openTestDB(t)
+	createOrdersTable(t)
+
+	tests := []struct {
+		name  string
+		model models.SelectModel
+		want  string
+	}{
+		{
+			name: "count per status",
+			model: models.SelectModel{
+				GroupBy:    []string{"status"},
+				Aggregates: []models.AggregateModel{{Function: "count"}},
+			},
+			want: `[{"count":1,"status":"open"},{"count":3,"status":"paid"},{"count":1,"status":"void"}]`,
+		},
+		{
+			name: "having on an aggregate alias",
+			model: models.SelectModel{
+				GroupBy:    []string{"status"},
+				Aggregates: []models.AggregateModel{{Function: "count", Alias: "orders"}},
+				Having:     &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "orders", Operator: "gt", Value: 1}},
+			},
+			want: `[{"orders":3,"status":"paid"}]`,
+		},
+		{
+			name: "sum of amount by day",
+			model: models.SelectModel{
+				Buckets:    []models.BucketModel{{Column: "created_at", Function: "date", Alias: "day"}},
+				Aggregates: []models.AggregateModel{{Function: "sum", Column: "amount", Alias: "total"}},
+				Order:      []models.OrderModel{{Column: "day", Direction: "desc"}},
+			},
+			want: `[{"day":"2024-02-11","total":1},{"day":"2024-02-10","total":2},{"day":"2024-01-02","total":7},{"day":"2024-01-01","total":15.5}]`,
+		},
+		{
+			name: "having on a bucket alias with a status column",
+			model: models.SelectModel{
+				GroupBy:    []string{"status"},
+				Buckets:    []models.BucketModel{{Column: "created_at", Function: "strftime", Format: "%Y-%m"}},
+				Aggregates: []models.AggregateModel{{Function: "count"}},
+				Having:     &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "strftime_created_at", Operator: "eq", Value: "2024-01"}},
+			},
+			want: `[{"count":1,"status":"open","strftime_created_at":"2024-01"},{"count":2,"status":"paid","strftime_created_at":"2024-01"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.model.TableName = "orders"
+			rows, _, err := selectRows(dbclass.DB, tt.model, 0)
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if got := encodeRows(t, rows); got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateSelectValidation(t *testing.T) {
+	openTestDB(t)
+	createOrdersTable(t)
+
+	tests := []struct {
+		name  string
+		model models.SelectModel
+		want  string
+	}{
+		{
+			name: "column that is not grouped",
+			model: models.SelectModel{
+				SelectedColumns: []string{"status", "amount"},
+				GroupBy:         []string{"status"},
+				Aggregates:      []models.AggregateModel{{Function: "count"}},
+			},
+			want: "column 'amount' should be part of group_by",
+		},
+		{
+			name: "having on a column that is not an output",
+			model: models.SelectModel{
+				GroupBy: []string{"status"},
+				Having:  &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "amount", Operator: "gt", Value: 1}},
+			},
+			want: "having column 'amount'",
+		},
+		{
+			name: "quote in a strftime format",
+			model: models.SelectModel{
+				Buckets: []models.BucketModel{{Column: "created_at", Function: "strftime", Format: "%Y') FROM orders --"}},
+			},
+			want: "invalid strftime format",
+		},
+		{
+			name: "unknown bucket function",
+			model: models.SelectModel{
+				Buckets: []models.BucketModel{{Column: "created_at", Function: "upper"}},
+			},
+			want: "invalid bucket function",
+		},
+		{
+			name: "bucket alias clashing with an aggregate alias",
+			model: models.SelectModel{
+				Buckets:    []models.BucketModel{{Column: "created_at", Function: "date", Alias: "count"}},
+				Aggregates: []models.AggregateModel{{Function: "count"}},
+			},
+			want: "duplicate output column 'count'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.model.TableName = "orders"
+			_, _, err := selectRows(dbclass.DB, tt.model, 0)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
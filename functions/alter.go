@@ -0,0 +1,614 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MultiX0/db-test/constants"
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+const (
+	AlterAddColumn    = "add_column"
+	AlterRenameColumn = "rename_column"
+	AlterDropColumn   = "drop_column"
+	AlterColumn       = "alter_column"
+	AlterEnumValues   = "set_enum_values"
+)
+
+// rebuildPrefix names the copy of a table while it is rebuilt
+const rebuildPrefix = "__rebuild_"
+
+// AlterTable changes the columns of a table and records the new schema in admin.db, adding, renaming and dropping
+// use SQLite's ALTER TABLE while changing a column's type or constraints rebuilds the table
+func AlterTable(alterModel models.AlterTableModel) error {
+	tableName := alterModel.TableName
+	if err := ValidateTableName(tableName); err != nil {
+		return err
+	}
+
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]models.ColumnModel)
+	for _, column := range *columnsPtr {
+		existing[column.Name] = column
+	}
+
+	action := strings.ToLower(strings.TrimSpace(alterModel.Action))
+	if action != AlterAddColumn {
+		if _, ok := existing[alterModel.Column]; !ok {
+			return fmt.Errorf("column '%s' does not exist in table '%s'", alterModel.Column, tableName)
+		}
+	}
+
+	switch action {
+	case AlterAddColumn:
+		return addColumn(tableName, existing, alterModel.Definition)
+	case AlterRenameColumn:
+		return renameColumn(tableName, existing, alterModel.Column, alterModel.NewName)
+	case AlterDropColumn:
+		return dropColumn(tableName, existing, alterModel.Column)
+	case AlterColumn:
+		return alterColumn(tableName, existing[alterModel.Column], alterModel.Definition)
+	case AlterEnumValues:
+		return setEnumValues(tableName, existing[alterModel.Column], alterModel.Values)
+	}
+
+	return fmt.Errorf("invalid alter action: %s, use add_column, rename_column, drop_column, alter_column or set_enum_values", alterModel.Action)
+}
+
+// validateNewColumnName checks a name given to an added or renamed column
+func validateNewColumnName(name string, existing map[string]models.ColumnModel) error {
+	if err := ValidateColumnName(name); err != nil {
+		return err
+	}
+
+	if name == "*" || strings.HasPrefix(name, rebuildPrefix) {
+		return fmt.Errorf("invalid column name: %s", name)
+	}
+
+	if _, ok := existing[name]; ok {
+		return fmt.Errorf("column '%s' already exists", name)
+	}
+
+	return nil
+}
+
+func validateDataType(dataType string) error {
+	if _, ok := constants.DataTypes[dataType]; !ok {
+		return fmt.Errorf("invalid data type: %s", dataType)
+	}
+	return nil
+}
+
+func addColumn(tableName string, existing map[string]models.ColumnModel, definition *models.ColumnModel) error {
+	if definition == nil {
+		return fmt.Errorf("add_column requires the column definition")
+	}
+
+	if err := validateNewColumnName(definition.Name, existing); err != nil {
+		return err
+	}
+
+	if err := validateDataType(definition.DataType); err != nil {
+		return err
+	}
+
+	if definition.IsPrimaryKey {
+		return fmt.Errorf("a primary key column cannot be added to an existing table")
+	}
+
+	if definition.Unique {
+		return fmt.Errorf("SQLite cannot add a unique column, add the column and then create a unique index on it")
+	}
+
+	columnSet := map[string]bool{definition.Name: true}
+	for name := range existing {
+		columnSet[name] = true
+	}
+	if err := validateColumnConstraints(*definition, columnSet); err != nil {
+		return err
+	}
+
+	if definition.Generated != nil {
+		generated, err := validateGenerated(*definition, columnSet)
+		if err != nil {
+			return err
+		}
+
+		// a stored column would have to be computed for every existing row
+		if generated.Mode == GeneratedStored {
+			return fmt.Errorf("SQLite cannot add a stored generated column to an existing table, use the virtual mode")
+		}
+		definition.Generated = generated
+	}
+
+	// SQLite fills the existing rows with the default, so a NOT NULL column needs one
+	hasDefault := definition.Default_Value != nil && strings.TrimSpace(*definition.Default_Value) != ""
+	if !definition.Nullable && !hasDefault && definition.Generated == nil {
+		return fmt.Errorf("column '%s' is not nullable so it needs a default value for the existing rows", definition.Name)
+	}
+
+	columnDefinition := buildColumnDefinition(*definition)
+	if definition.References != nil {
+		var columns []models.ColumnModel
+		for _, column := range existing {
+			columns = append(columns, column)
+		}
+
+		ref, err := validateReference(tableName, *definition, columns)
+		if err != nil {
+			return err
+		}
+
+		// with foreign keys enforced SQLite only adds a referencing column whose default is NULL
+		columnDefinition += " " + referenceClause(ref)
+	}
+
+	err := execSchemaStatements(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, columnDefinition))
+	if err != nil {
+		return err
+	}
+
+	return recordAlteredTable(tableName, nil, definition)
+}
+
+func renameColumn(tableName string, existing map[string]models.ColumnModel, column string, newName string) error {
+	if err := validateNewColumnName(newName, existing); err != nil {
+		return err
+	}
+
+	// SQLite rewrites the indexes, triggers, views and foreign keys that use the column
+	err := execSchemaStatements(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, column, newName))
+	if err != nil {
+		return err
+	}
+
+	return recordAlteredTable(tableName, map[string]string{column: newName}, nil)
+}
+
+func dropColumn(tableName string, existing map[string]models.ColumnModel, column string) error {
+	if existing[column].IsPrimaryKey {
+		return fmt.Errorf("column '%s' is part of the primary key and cannot be dropped", column)
+	}
+
+	if len(existing) == 1 {
+		return fmt.Errorf("column '%s' is the only column of table '%s', drop the table instead", column, tableName)
+	}
+
+	// SQLite refuses to drop columns that are indexed, unique or used by a constraint, trigger or view
+	err := execSchemaStatements(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column))
+	if err != nil {
+		return err
+	}
+
+	return recordAlteredTable(tableName, nil, nil)
+}
+
+// alterColumn replaces the definition of a column by rebuilding the table,
+// the column keeps its name, its primary key membership and its foreign key
+func alterColumn(tableName string, current models.ColumnModel, definition *models.ColumnModel) error {
+	if definition == nil {
+		return fmt.Errorf("alter_column requires the new column definition")
+	}
+
+	if definition.Name != "" && definition.Name != current.Name {
+		return fmt.Errorf("alter_column cannot rename the column, use rename_column")
+	}
+
+	if err := validateDataType(definition.DataType); err != nil {
+		return err
+	}
+
+	if definition.References != nil {
+		return fmt.Errorf("alter_column keeps the foreign key of column '%s', it cannot be changed", current.Name)
+	}
+
+	// the rows are copied by the rebuild, which cannot write to a generated column
+	if definition.Generated != nil || current.Generated != nil {
+		return fmt.Errorf("alter_column cannot change generated columns, drop column '%s' and add it again", current.Name)
+	}
+
+	altered := *definition
+	altered.Name = current.Name
+	altered.IsPrimaryKey = current.IsPrimaryKey
+
+	columnSet, err := getColumnSet(tableName)
+	if err != nil {
+		return err
+	}
+	if err := validateColumnConstraints(altered, columnSet); err != nil {
+		return err
+	}
+
+	err = rebuildTable(tableName, func(definitions []string) ([]string, error) {
+		for i, columnDefinition := range definitions {
+			if isTableConstraint(columnDefinition) || definitionName(columnDefinition) != current.Name {
+				continue
+			}
+
+			definitions[i] = buildColumnDefinition(altered) + keptColumnConstraints(columnDefinition)
+			return definitions, nil
+		}
+
+		return nil, fmt.Errorf("could not find the definition of column '%s'", current.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	return recordAlteredTable(tableName, nil, &altered)
+}
+
+// setEnumValues replaces the allowed values of an enum column. When no value is removed the stored rows all still
+// pass, so only the CHECK constraint in the schema is rewritten, otherwise the table is rebuilt to check every row
+func setEnumValues(tableName string, current models.ColumnModel, values []string) error {
+	if current.LogicalType != LogicalTypeEnum {
+		return fmt.Errorf("column '%s' is not an enum column", current.Name)
+	}
+
+	altered := current
+	altered.EnumValues = values
+	if err := validateEnumValues(altered); err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool)
+	for _, value := range values {
+		allowed[value] = true
+	}
+
+	removed := false
+	for _, value := range current.EnumValues {
+		if !allowed[value] {
+			removed = true
+			break
+		}
+	}
+
+	var err error
+	if removed {
+		err = rebuildTable(tableName, func(definitions []string) ([]string, error) {
+			for i, columnDefinition := range definitions {
+				if isTableConstraint(columnDefinition) || definitionName(columnDefinition) != current.Name {
+					continue
+				}
+
+				definitions[i], err = replaceEnumConstraint(columnDefinition, current.Name, values)
+				return definitions, err
+			}
+
+			return nil, fmt.Errorf("could not find the definition of column '%s'", current.Name)
+		})
+	} else {
+		err = rewriteTableSQL(tableName, func(tableSQL string) (string, error) {
+			return replaceEnumConstraint(tableSQL, current.Name, values)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return recordAlteredTable(tableName, nil, nil)
+}
+
+// rewriteTableSQL edits the CREATE TABLE statement of a table in place, following the writable_schema procedure
+// from SQLite's ALTER TABLE documentation. It is only safe for changes every stored row already satisfies,
+// like a CHECK constraint that allows more values than before
+func rewriteTableSQL(tableName string, rewrite func(tableSQL string) (string, error)) error {
+	tableSQL, err := getTableSQL(tableName)
+	if err != nil {
+		return err
+	}
+
+	newSQL, err := rewrite(tableSQL)
+	if err != nil {
+		return err
+	}
+
+	// writable_schema is a setting of the connection, it must not leak to the rest of the pool
+	ctx := context.Background()
+	conn, err := dbclass.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	defer conn.ExecContext(ctx, "PRAGMA writable_schema = OFF")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow("PRAGMA schema_version").Scan(&version); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("PRAGMA writable_schema = ON"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE sqlite_schema SET sql = ? WHERE type = 'table' AND name = ?", newSQL, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to change the schema: %v", err)
+	}
+
+	// a new schema version makes every connection load the changed statement
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA schema_version = %d", version+1)); err != nil {
+		return fmt.Errorf("failed to change the schema: %v", err)
+	}
+	if _, err := tx.Exec("PRAGMA writable_schema = OFF"); err != nil {
+		return err
+	}
+
+	var check string
+	if err := tx.QueryRow("PRAGMA quick_check").Scan(&check); err != nil {
+		return fmt.Errorf("failed to check the changed schema: %v", err)
+	}
+	if check != "ok" {
+		return fmt.Errorf("the changed schema of table '%s' did not pass the integrity check: %s", tableName, check)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// keptColumnConstraints returns the inline primary key and foreign key of a column definition,
+// which stay when the rest of the definition is replaced
+func keptColumnConstraints(definition string) string {
+	upper := strings.ToUpper(definition)
+
+	var kept string
+	if strings.Contains(upper, "PRIMARY KEY") {
+		kept += " PRIMARY KEY"
+		if strings.Contains(upper, "AUTOINCREMENT") {
+			kept += " AUTOINCREMENT"
+		}
+	}
+
+	if i := strings.Index(upper, "REFERENCES "); i >= 0 {
+		kept += " " + definition[i:]
+	}
+
+	return kept
+}
+
+// execSchemaStatements runs schema changes in one transaction
+func execSchemaStatements(statements ...string) error {
+	tx, err := dbclass.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to change the schema: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// rebuildTable applies a change SQLite's ALTER TABLE cannot make, rewrite receives the column definitions and
+// table constraints of the CREATE TABLE statement and returns the new ones. The table is recreated under a temporary
+// name, the rows are copied over, the old table is dropped and the copy renamed, then the indexes and triggers are
+// created again, all inside one transaction
+func rebuildTable(tableName string, rewrite func(definitions []string) ([]string, error)) error {
+	tableSQL, err := getTableSQL(tableName)
+	if err != nil {
+		return err
+	}
+
+	body, options, err := splitTableSQL(tableName, tableSQL)
+	if err != nil {
+		return err
+	}
+
+	definitions, err := splitDefinitions(body)
+	if err != nil {
+		return fmt.Errorf("could not parse the schema of table '%s': %v", tableName, err)
+	}
+
+	definitions, err = rewrite(definitions)
+	if err != nil {
+		return err
+	}
+
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return err
+	}
+
+	// generated columns are computed again by the new table
+	var columns []string
+	for _, column := range *columnsPtr {
+		if column.Generated == nil {
+			columns = append(columns, column.Name)
+		}
+	}
+	columnList := strings.Join(columns, ", ")
+
+	// the options after the column list, like WITHOUT ROWID or STRICT, are kept as they are
+	rebuildName := rebuildPrefix + tableName
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (%s)%s", rebuildName, strings.Join(definitions, ", "), options),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", rebuildName, columnList, columnList, tableName),
+		fmt.Sprintf("DROP TABLE %s", tableName),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", rebuildName, tableName),
+	}
+
+	referencing, err := getReferencingTables(tableName)
+	if err != nil {
+		return err
+	}
+
+	// foreign key enforcement and the rename checks of newer SQLite versions are switched off on this
+	// connection while the table is briefly missing, they cannot be changed inside a transaction
+	ctx := context.Background()
+	conn, err := dbclass.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var foreignKeys int
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, fmt.Sprintf("PRAGMA foreign_keys = %d", foreignKeys))
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA legacy_alter_table = ON"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA legacy_alter_table = OFF")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// indexes and triggers are dropped with the old table, the automatic indexes of constraints have no sql
+	rows, err := tx.Query("SELECT sql FROM sqlite_schema WHERE tbl_name = ? AND type IN ('index', 'trigger') AND sql IS NOT NULL", tableName)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			rows.Close()
+			return err
+		}
+		statements = append(statements, statement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild table: %v", err)
+		}
+	}
+
+	// with enforcement off nothing was checked, neither the copied rows against the foreign keys of the table
+	// nor the rows of the tables referencing it against the changed keys
+	for _, checked := range append([]string{tableName}, referencing...) {
+		violations, err := tx.Query(fmt.Sprintf("PRAGMA foreign_key_check(%s)", checked))
+		if err != nil {
+			// a foreign key whose parent column is no longer unique fails the check itself
+			return fmt.Errorf("the change would break the foreign keys of table '%s': %v", checked, err)
+		}
+		broken := violations.Next()
+		violations.Close()
+		if broken && checked == tableName {
+			return fmt.Errorf("the change would break foreign key references of table '%s'", tableName)
+		}
+		if broken {
+			return fmt.Errorf("the change would break the foreign keys of table '%s' referencing table '%s'", checked, tableName)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// splitTableSQL returns the definitions between the parentheses of a CREATE TABLE statement and the table options after them
+func splitTableSQL(tableName string, tableSQL string) (string, string, error) {
+	open := strings.Index(tableSQL, "(")
+	close := strings.LastIndex(tableSQL, ")")
+	if open < 0 || close < open {
+		return "", "", fmt.Errorf("could not parse the schema of table '%s'", tableName)
+	}
+
+	return tableSQL[open+1 : close], tableSQL[close+1:], nil
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement on the commas that are not
+// inside parentheses, quoted identifiers or string literals
+func splitDefinitions(body string) ([]string, error) {
+	var definitions []string
+	depth := 0
+	start := 0
+	var quote rune
+
+	for i, char := range body {
+		if quote != 0 {
+			if char == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch char {
+		case '\'', '"', '`':
+			quote = char
+		case '[':
+			quote = ']'
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 || quote != 0 {
+		return nil, fmt.Errorf("unterminated definition")
+	}
+
+	return append(definitions, strings.TrimSpace(body[start:])), nil
+}
+
+// isTableConstraint reports whether a CREATE TABLE definition is a table constraint rather than a column
+func isTableConstraint(definition string) bool {
+	fields := strings.Fields(definition)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch strings.ToUpper(strings.SplitN(fields[0], "(", 2)[0]) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+		return true
+	}
+	return false
+}
+
+// definitionName returns the column name of a column definition without its quotes
+func definitionName(definition string) string {
+	fields := strings.Fields(definition)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Trim(fields[0], "\"`[]")
+}
@@ -0,0 +1,52 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestAlterColumnChecksTheTablesReferencingIt(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "authors",
+		KeyStrategy: "autoincrement",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "code", DataType: "txt", Unique: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create authors: %v", err)
+	}
+	err = CreateTable(models.TableModel{
+		Name:        "books",
+		KeyStrategy: "autoincrement",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "author_code", DataType: "txt", References: &models.ReferenceModel{Table: "authors", Column: "code"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create books: %v", err)
+	}
+	mustExec(t, "INSERT INTO authors (id, code) VALUES (1, 'ann')")
+	mustExec(t, "INSERT INTO books (id, author_code) VALUES (1, 'ann')")
+
+	// without the unique constraint code can no longer be referenced by books
+	err = AlterTable(models.AlterTableModel{
+		TableName:  "authors",
+		Action:     "alter_column",
+		Column:     "code",
+		Definition: &models.ColumnModel{DataType: "txt"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "books") {
+		t.Fatalf("got %v, want the foreign keys of books reported as broken", err)
+	}
+
+	if _, err := dbclass.DB.Exec("INSERT INTO books (id, author_code) VALUES (2, 'ann')"); err != nil {
+		t.Fatalf("books should still be writable after the rejected change: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+const MaxBatchOperations = 100
+
+const (
+	BatchInsert = "insert"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+	BatchSelect = "select"
+)
+
+// BatchError reports which operation stopped the batch, everything before it was rolled back
+type BatchError struct {
+	Index int
+	Ref   string
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	if e.Ref != "" {
+		return fmt.Sprintf("operation %d (%s) failed: %v", e.Index, e.Ref, e.Err)
+	}
+	return fmt.Sprintf("operation %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batchOutput keeps what later operations can reference, the generated ids of an insert
+// or the rows of a select/delete
+type batchOutput struct {
+	ids  []any
+	rows []map[string]any
+}
+
+// ExecuteBatch runs the operations in order inside a single transaction,
+// the first failing operation rolls back the whole batch
+func ExecuteBatch(batch models.BatchModel) ([]models.BatchResult, error) {
+	if len(batch.Operations) == 0 {
+		return nil, fmt.Errorf("batch should contain at least one operation")
+	}
+
+	if len(batch.Operations) > MaxBatchOperations {
+		return nil, fmt.Errorf("batch cannot contain more than %d operations", MaxBatchOperations)
+	}
+
+	refs := make(map[string]bool, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		if operation.Ref == "" {
+			continue
+		}
+		if refs[operation.Ref] {
+			return nil, &BatchError{Index: i, Ref: operation.Ref, Err: fmt.Errorf("duplicate ref %s", operation.Ref)}
+		}
+		refs[operation.Ref] = true
+	}
+
+	tx, err := dbclass.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	outputs := make(map[string]*batchOutput, len(refs))
+	results := make([]models.BatchResult, len(batch.Operations))
+	for i, operation := range batch.Operations {
+		result, output, err := runBatchOperation(tx, operation, outputs)
+		if err != nil {
+			return nil, &BatchError{Index: i, Ref: operation.Ref, Err: err}
+		}
+
+		if operation.Ref != "" {
+			outputs[operation.Ref] = output
+		}
+		results[i] = models.BatchResult{Ref: operation.Ref, Type: operation.Type, Result: result}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return results, nil
+}
+
+func runBatchOperation(tx dbclass.Querier, operation models.BatchOperation, outputs map[string]*batchOutput) (any, *batchOutput, error) {
+	body, err := resolveBatchRefs(operation.Body, outputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch operation.Type {
+	case BatchInsert:
+		var insertModel models.InsertModel
+		if err := decodeBatchBody(body, &insertModel); err != nil {
+			return nil, nil, err
+		}
+
+		// a failing row fails the whole batch, skipping it would commit the rest and leave its ids empty
+		if insertModel.ContinueOnError {
+			return nil, nil, fmt.Errorf("continue_on_error cannot be used in a batch, the first failing row rolls back the whole batch")
+		}
+
+		if len(insertModel.Rows) > 0 {
+			result, err := bulkInsertIntoTable(tx, insertModel)
+			if err != nil {
+				return nil, nil, err
+			}
+			return result, &batchOutput{ids: result.IDs}, nil
+		}
+
+		result, err := insertIntoTable(tx, insertModel)
+		if err != nil {
+			return nil, nil, err
+		}
+		return result, &batchOutput{ids: []any{result.ID}}, nil
+
+	case BatchUpdate:
+		var updateModel models.UpdateModel
+		if err := decodeBatchBody(body, &updateModel); err != nil {
+			return nil, nil, err
+		}
+
+		affected, err := updateTable(tx, updateModel)
+		if err != nil {
+			return nil, nil, err
+		}
+		return map[string]any{"affected_rows": affected}, &batchOutput{}, nil
+
+	case BatchDelete:
+		var deleteModel models.DeleteModel
+		if err := decodeBatchBody(body, &deleteModel); err != nil {
+			return nil, nil, err
+		}
+
+		affected, deleted, err := deleteFromTable(tx, deleteModel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result := map[string]any{"affected_rows": affected}
+		if deleteModel.Returning {
+			if deleted == nil {
+				deleted = []map[string]any{}
+			}
+			result["data"] = deleted
+		}
+		return result, &batchOutput{rows: deleted}, nil
+
+	case BatchSelect:
+		var selectModel models.SelectModel
+		if err := decodeBatchBody(body, &selectModel); err != nil {
+			return nil, nil, err
+		}
+
+		rows, nextCursor, err := selectRows(tx, selectModel, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result := map[string]any{"data": rows, "next_cursor": nil}
+		if nextCursor != "" {
+			result["next_cursor"] = nextCursor
+		}
+		return result, &batchOutput{rows: rows}, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported operation type %q, use insert, update, delete or select", operation.Type)
+}
+
+func decodeBatchBody(body []byte, v any) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid operation body: %v", err)
+	}
+	return nil
+}
+
+// resolveBatchRefs replaces every {"$ref": "<ref>"} object in the body with a value produced by an earlier
+// operation, the generated id of an insert by default, "index" picks a row of a bulk insert or select
+// and "field" picks a column of a select or delete returning row
+func resolveBatchRefs(body json.RawMessage, outputs map[string]*batchOutput) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, fmt.Errorf("operation body is required")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("invalid operation body: %v", err)
+	}
+
+	resolved, err := resolveBatchValue(tree, outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resolved)
+}
+
+func resolveBatchValue(value any, outputs map[string]*batchOutput) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"]; ok {
+			return resolveBatchRef(ref, v, outputs)
+		}
+		for key, item := range v {
+			resolved, err := resolveBatchValue(item, outputs)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+	case []any:
+		for i, item := range v {
+			resolved, err := resolveBatchValue(item, outputs)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return value, nil
+}
+
+func resolveBatchRef(ref any, node map[string]any, outputs map[string]*batchOutput) (any, error) {
+	name, ok := ref.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("$ref should be the ref name of an earlier operation")
+	}
+
+	output, ok := outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("$ref %s does not match an earlier operation", name)
+	}
+
+	index := 0
+	if raw, ok := node["index"]; ok {
+		number, ok := raw.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("$ref %s: index should be a number", name)
+		}
+		parsed, err := number.Int64()
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("$ref %s: index should be a non negative integer", name)
+		}
+		index = int(parsed)
+	}
+
+	field, hasField := node["field"].(string)
+	if !hasField {
+		if index >= len(output.ids) {
+			return nil, fmt.Errorf("$ref %s has no generated id at index %d", name, index)
+		}
+		if output.ids[index] == nil {
+			return nil, fmt.Errorf("$ref %s: row %d was not inserted", name, index)
+		}
+		return output.ids[index], nil
+	}
+
+	if index >= len(output.rows) {
+		return nil, fmt.Errorf("$ref %s has no row at index %d", name, index)
+	}
+
+	fieldValue, ok := output.rows[index][field]
+	if !ok {
+		return nil, fmt.Errorf("$ref %s: row %d has no column %s", name, index, field)
+	}
+	return fieldValue, nil
+}
@@ -0,0 +1,162 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func createBatchTables(t *testing.T) {
+	t.Helper()
+	tables := []models.TableModel{
+		{
+			Name:        "teams",
+			KeyStrategy: "autoincrement",
+			Columns: []models.ColumnModel{
+				{Name: "id", DataType: "int", IsPrimaryKey: true},
+				{Name: "name", DataType: "txt"},
+			},
+		},
+		{
+			Name:        "players",
+			KeyStrategy: "autoincrement",
+			Columns: []models.ColumnModel{
+				{Name: "id", DataType: "int", IsPrimaryKey: true},
+				{Name: "team_id", DataType: "int", References: &models.ReferenceModel{Table: "teams"}},
+				{Name: "name", DataType: "txt"},
+			},
+		},
+	}
+	for _, table := range tables {
+		if err := CreateTable(table); err != nil {
+			t.Fatalf("create table %s: %v", table.Name, err)
+		}
+	}
+}
+
+func batchOperation(ref string, operationType string, body string) models.BatchOperation {
+	return models.BatchOperation{Ref: ref, Type: operationType, Body: json.RawMessage(body)}
+}
+
+func countRows(t *testing.T, tableName string) int {
+	t.Helper()
+	var count int
+	if err := dbclass.DB.QueryRow("SELECT COUNT(*) FROM " + tableName).Scan(&count); err != nil {
+		t.Fatalf("count %s: %v", tableName, err)
+	}
+	return count
+}
+
+func TestBatchResolvesGeneratedIDs(t *testing.T) {
+	openTestDB(t)
+	createBatchTables(t)
+	mustExec(t, "INSERT INTO teams (id, name) VALUES (41, 'existing')")
+
+	results, err := ExecuteBatch(models.BatchModel{Operations: []models.BatchOperation{
+		batchOperation("team", BatchInsert, `{"table":"teams","columns":["name"],"values":["reds"]}`),
+		batchOperation("players", BatchInsert, `{"table":"players","columns":["team_id","name"],"rows":[[{"$ref":"team"},"ann"],[{"$ref":"team","index":0},"bob"]]}`),
+		batchOperation("", BatchSelect, `{"table":"players","columns":["name"],"where":{"column":"id","operator":"eq","value":{"$ref":"players","index":1}}}`),
+	}})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+
+	teamID := results[0].Result.(*models.InsertResult).ID
+	if teamID != int64(42) {
+		t.Fatalf("got team id %v, want 42", teamID)
+	}
+
+	var teamIDs int
+	if err := dbclass.DB.QueryRow("SELECT COUNT(*) FROM players WHERE team_id = 42").Scan(&teamIDs); err != nil {
+		t.Fatalf("count players: %v", err)
+	}
+	if teamIDs != 2 {
+		t.Fatalf("got %d players of the new team, want 2", teamIDs)
+	}
+
+	rows := results[2].Result.(map[string]any)["data"].([]map[string]any)
+	if len(rows) != 1 || rows[0]["name"] != "bob" {
+		t.Fatalf("got %v, want the second inserted player", rows)
+	}
+}
+
+func TestBatchRollsBackOnFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []models.BatchOperation
+		index      int
+		want       string
+	}{
+		{
+			name: "failing middle operation",
+			operations: []models.BatchOperation{
+				batchOperation("team", BatchInsert, `{"table":"teams","columns":["name"],"values":["reds"]}`),
+				batchOperation("", BatchInsert, `{"table":"players","columns":["team_id","name"],"values":[999,"ann"]}`),
+				batchOperation("", BatchInsert, `{"table":"teams","columns":["name"],"values":["blues"]}`),
+			},
+			index: 1,
+			want:  "FOREIGN KEY",
+		},
+		{
+			name: "ref index past the inserted rows",
+			operations: []models.BatchOperation{
+				batchOperation("team", BatchInsert, `{"table":"teams","columns":["name"],"values":["reds"]}`),
+				batchOperation("", BatchInsert, `{"table":"players","columns":["team_id","name"],"values":[{"$ref":"team","index":3},"ann"]}`),
+			},
+			index: 1,
+			want:  "has no generated id at index 3",
+		},
+		{
+			name: "continue_on_error",
+			operations: []models.BatchOperation{
+				batchOperation("team", BatchInsert, `{"table":"teams","columns":["name"],"values":["reds"]}`),
+				batchOperation("", BatchInsert, `{"table":"players","columns":["team_id","name"],"rows":[[1,"ann"],[999,"bob"]],"continue_on_error":true}`),
+			},
+			index: 1,
+			want:  "continue_on_error cannot be used in a batch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			createBatchTables(t)
+
+			_, err := ExecuteBatch(models.BatchModel{Operations: tt.operations})
+
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) || batchErr.Index != tt.index || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want operation %d to fail with %q", err, tt.index, tt.want)
+			}
+
+			if teams, players := countRows(t, "teams"), countRows(t, "players"); teams != 0 || players != 0 {
+				t.Fatalf("got %d teams and %d players, want the batch rolled back", teams, players)
+			}
+		})
+	}
+}
+
+func TestBatchOperationLimit(t *testing.T) {
+	openTestDB(t)
+	createBatchTables(t)
+
+	operations := make([]models.BatchOperation, MaxBatchOperations+1)
+	for i := range operations {
+		operations[i] = batchOperation("", BatchInsert, `{"table":"teams","columns":["name"],"values":["reds"]}`)
+	}
+
+	if _, err := ExecuteBatch(models.BatchModel{Operations: operations}); err == nil || !strings.Contains(err.Error(), "more than 100 operations") {
+		t.Fatalf("got error %v, want the operation limit to be enforced", err)
+	}
+
+	if _, err := ExecuteBatch(models.BatchModel{Operations: operations[:MaxBatchOperations]}); err != nil {
+		t.Fatalf("a batch of %d operations should run: %v", MaxBatchOperations, err)
+	}
+	if count := countRows(t, "teams"); count != MaxBatchOperations {
+		t.Fatalf("got %d teams, want %d", count, MaxBatchOperations)
+	}
+}
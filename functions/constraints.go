@@ -0,0 +1,494 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	ConstraintUnique     = "unique"
+	ConstraintPrimaryKey = "primary_key"
+	ConstraintCheck      = "check"
+	ConstraintNotNull    = "not_null"
+	ConstraintForeignKey = "foreign_key"
+)
+
+// checkKeywords are the SQL words a CHECK expression may use besides column names, literals and checkFunctions
+var checkKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "ISNULL": true, "NOTNULL": true,
+	"IN": true, "BETWEEN": true, "LIKE": true, "GLOB": true, "ESCAPE": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "TRUE": true, "FALSE": true,
+	"CAST": true, "AS": true, "COLLATE": true, "NOCASE": true, "BINARY": true, "RTRIM": true,
+	"INTEGER": true, "INT": true, "TEXT": true, "REAL": true, "NUMERIC": true, "BLOB": true,
+}
+
+// checkFunctions are the deterministic SQLite functions a CHECK expression may call
+var checkFunctions = map[string]bool{
+	"abs": true, "coalesce": true, "ifnull": true, "nullif": true, "iif": true, "instr": true,
+	"length": true, "lower": true, "upper": true, "ltrim": true, "rtrim": true, "trim": true,
+	"max": true, "min": true, "replace": true, "round": true, "sign": true, "substr": true, "substring": true,
+	"typeof": true, "unicode": true, "hex": true, "json_valid": true, "json_type": true, "json_array_length": true, "json_extract": true,
+	"date": true, "time": true, "datetime": true, "julianday": true, "strftime": true, "unixepoch": true,
+}
+
+// checkOperators are the operators of a CHECK expression, the two character ones come first
+var checkOperators = []string{"||", "<=", ">=", "<>", "!=", "==", "<<", ">>", "=", "<", ">", "+", "-", "*", "/", "%", ",", "&", "|", "~"}
+
+// validateCheckExpression checks a CHECK expression against a small grammar of column names, number and string
+// literals, operators, parentheses, checkKeywords and calls to checkFunctions. Anything else, like quoted identifiers,
+// subqueries, comments or a second statement, is refused before it reaches the schema
+func validateCheckExpression(expression string, columns map[string]bool) error {
+	return validateColumnExpression("check", expression, columns)
+}
+
+// validateColumnExpression checks an expression stored in the schema with the grammar of validateCheckExpression,
+// kind names the expression in the errors
+func validateColumnExpression(kind string, expression string, columns map[string]bool) error {
+	if strings.TrimSpace(expression) == "" {
+		return fmt.Errorf("%s expression cannot be empty", kind)
+	}
+
+	depth := 0
+	for i := 0; i < len(expression); {
+		char := expression[i]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			i++
+
+		case char == '\'':
+			// a quote inside a string literal is written twice
+			end := i + 1
+			for {
+				next := strings.IndexByte(expression[end:], '\'')
+				if next < 0 {
+					return fmt.Errorf("invalid %s expression %s: unterminated string", kind, expression)
+				}
+				end += next + 1
+				if end < len(expression) && expression[end] == '\'' {
+					end++
+					continue
+				}
+				break
+			}
+			i = end
+
+		case isDigit(char) || (char == '.' && i+1 < len(expression) && isDigit(expression[i+1])):
+			i++
+			for i < len(expression) && (isDigit(expression[i]) || expression[i] == '.') {
+				i++
+			}
+			if i < len(expression) && (expression[i] == 'e' || expression[i] == 'E') {
+				i++
+				if i < len(expression) && (expression[i] == '+' || expression[i] == '-') {
+					i++
+				}
+				for i < len(expression) && isDigit(expression[i]) {
+					i++
+				}
+			}
+
+		case isIdentifierStart(char):
+			start := i
+			for i < len(expression) && (isIdentifierStart(expression[i]) || isDigit(expression[i])) {
+				i++
+			}
+			word := expression[start:i]
+
+			if checkKeywords[strings.ToUpper(word)] {
+				continue
+			}
+
+			if strings.HasPrefix(strings.TrimLeft(expression[i:], " \t\r\n"), "(") {
+				if !checkFunctions[strings.ToLower(word)] {
+					return fmt.Errorf("invalid %s expression %s: function %s is not allowed", kind, expression, word)
+				}
+				continue
+			}
+
+			if !columns[word] {
+				return fmt.Errorf("invalid %s expression %s: unknown column %s", kind, expression, word)
+			}
+
+		case char == '(':
+			depth++
+			i++
+
+		case char == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("invalid %s expression %s: unbalanced parentheses", kind, expression)
+			}
+			i++
+
+		default:
+			if strings.HasPrefix(expression[i:], "--") || strings.HasPrefix(expression[i:], "/*") {
+				return fmt.Errorf("invalid %s expression %s: comments are not allowed", kind, expression)
+			}
+
+			operator := ""
+			for _, candidate := range checkOperators {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return fmt.Errorf("invalid %s expression %s: unexpected character %q", kind, expression, char)
+			}
+			i += len(operator)
+		}
+	}
+
+	if depth != 0 {
+		return fmt.Errorf("invalid %s expression %s: unbalanced parentheses", kind, expression)
+	}
+
+	return nil
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isIdentifierStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+// validateColumnConstraints checks the allowed values of an enum column and the CHECK expression of a column,
+// columns are the names the expression may use
+func validateColumnConstraints(column models.ColumnModel, columns map[string]bool) error {
+	if column.DataType == LogicalTypeEnum {
+		if err := validateEnumValues(column); err != nil {
+			return err
+		}
+	} else if len(column.EnumValues) > 0 {
+		return fmt.Errorf("column '%s' has enum_values but its type is not enum", column.Name)
+	}
+
+	if column.Check == "" {
+		return nil
+	}
+
+	if err := validateCheckExpression(column.Check, columns); err != nil {
+		return fmt.Errorf("column '%s': %v", column.Name, err)
+	}
+	return nil
+}
+
+// buildTableConstraints renders the multi column unique constraints and row checks of a CreateTable model,
+// constraints without a name are named after the table so violations can be reported by name
+func buildTableConstraints(table models.TableModel) ([]string, error) {
+	columns := make(map[string]bool)
+	for _, column := range table.Columns {
+		columns[column.Name] = true
+	}
+
+	names := make(map[string]bool)
+	validateName := func(name string) error {
+		if err := ValidateColumnName(name); err != nil || name == "*" {
+			return fmt.Errorf("invalid constraint name format: %s", name)
+		}
+		if names[strings.ToLower(name)] {
+			return fmt.Errorf("constraint name '%s' is used more than once", name)
+		}
+		names[strings.ToLower(name)] = true
+		return nil
+	}
+
+	var constraints []string
+	for _, unique := range table.Uniques {
+		if len(unique.Columns) == 0 {
+			return nil, fmt.Errorf("a unique constraint needs at least one column")
+		}
+
+		seen := make(map[string]bool)
+		for _, column := range unique.Columns {
+			if !columns[column] {
+				return nil, fmt.Errorf("unique constraint column '%s' is not a column of table '%s'", column, table.Name)
+			}
+			if seen[column] {
+				return nil, fmt.Errorf("unique constraint column '%s' is listed more than once", column)
+			}
+			seen[column] = true
+		}
+
+		name := unique.Name
+		if name == "" {
+			name = fmt.Sprintf("%s_%s_unique", table.Name, strings.Join(unique.Columns, "_"))
+		}
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+
+		constraints = append(constraints, fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", name, strings.Join(unique.Columns, ", ")))
+	}
+
+	for i, check := range table.Checks {
+		if err := validateCheckExpression(check.Expression, columns); err != nil {
+			return nil, err
+		}
+
+		name := check.Name
+		if name == "" {
+			name = fmt.Sprintf("%s_check_%d", table.Name, i+1)
+		}
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+
+		constraints = append(constraints, fmt.Sprintf("CONSTRAINT %s CHECK (%s)", name, check.Expression))
+	}
+
+	return constraints, nil
+}
+
+// tableConstraints are the unique and check constraints read back from a CREATE TABLE statement
+type tableConstraints struct {
+	ColumnUniques map[string]string                      // column -> constraint name, empty for unnamed constraints
+	ColumnChecks  map[string]models.CheckConstraintModel // column -> its check
+	Uniques       []models.UniqueConstraintModel
+	Checks        []models.CheckConstraintModel
+}
+
+var (
+	columnConstraintPattern = regexp.MustCompile(`(?i)(?:\bCONSTRAINT\s+(\S+)\s+)?\b(UNIQUE|CHECK)\b`)
+	tableConstraintPattern  = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+(\S+)\s+)?(UNIQUE|CHECK)\s*\(`)
+)
+
+// parseTableConstraints reads the unique and check constraints of a table, the uuid checks are left out since
+// they are reported as logical types
+func parseTableConstraints(tableName string, tableSQL string) (*tableConstraints, error) {
+	constraints := &tableConstraints{
+		ColumnUniques: make(map[string]string),
+		ColumnChecks:  make(map[string]models.CheckConstraintModel),
+	}
+	if tableSQL == "" {
+		return constraints, nil
+	}
+
+	body, _, err := splitTableSQL(tableName, tableSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := splitDefinitions(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, definition := range definitions {
+		if isTableConstraint(definition) {
+			match := tableConstraintPattern.FindStringSubmatchIndex(definition)
+			if match == nil {
+				continue
+			}
+
+			name := unquoteIdentifier(submatch(definition, match, 1))
+			inside := definition[match[1]:]
+			end := closingParenthesis(inside)
+			if end < 0 {
+				continue
+			}
+
+			if strings.EqualFold(submatch(definition, match, 2), "UNIQUE") {
+				terms, err := splitDefinitions(inside[:end])
+				if err != nil {
+					continue
+				}
+				unique := models.UniqueConstraintModel{Name: name}
+				for _, term := range terms {
+					unique.Columns = append(unique.Columns, definitionName(term))
+				}
+				constraints.Uniques = append(constraints.Uniques, unique)
+			} else {
+				constraints.Checks = append(constraints.Checks, models.CheckConstraintModel{Name: name, Expression: strings.TrimSpace(inside[:end])})
+			}
+			continue
+		}
+
+		column := definitionName(definition)
+		for position := 0; position < len(definition); {
+			match := columnConstraintPattern.FindStringSubmatchIndex(definition[position:])
+			if match == nil {
+				break
+			}
+
+			rest := definition[position:]
+			name := unquoteIdentifier(submatch(rest, match, 1))
+			position += match[1]
+
+			if strings.EqualFold(submatch(rest, match, 2), "UNIQUE") {
+				constraints.ColumnUniques[column] = name
+				continue
+			}
+
+			open := strings.Index(definition[position:], "(")
+			if open < 0 {
+				break
+			}
+			start := position + open + 1
+			end := closingParenthesis(definition[start:])
+			if end < 0 {
+				break
+			}
+			position = start + end + 1
+
+			if !isLogicalConstraint(name) {
+				constraints.ColumnChecks[column] = models.CheckConstraintModel{Name: name, Expression: strings.TrimSpace(definition[start : start+end])}
+			}
+		}
+	}
+
+	return constraints, nil
+}
+
+// submatch returns group i of a FindStringSubmatchIndex match, empty when the group did not take part
+func submatch(s string, match []int, i int) string {
+	if match[2*i] < 0 {
+		return ""
+	}
+	return s[match[2*i]:match[2*i+1]]
+}
+
+func unquoteIdentifier(name string) string {
+	return strings.Trim(name, "\"`[]")
+}
+
+// ConstraintError is a write refused by a constraint of the schema
+type ConstraintError struct {
+	Type       string // unique, primary_key, check, not_null or foreign_key
+	Constraint string // the name of the constraint, for unnamed checks the expression
+	Table      string
+	Columns    []string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// AsConstraintError describes err when a constraint refused the write, nil for every other error.
+// SQLite names the columns of unique and not null violations and the constraint of check violations,
+// the name of a unique constraint is looked up in the schema of the table
+func AsConstraintError(err error) *ConstraintError {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return nil
+	}
+
+	constraintErr := &ConstraintError{Err: err}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique:
+		constraintErr.Type = ConstraintUnique
+	case sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintRowID:
+		constraintErr.Type = ConstraintPrimaryKey
+	case sqlite3.ErrConstraintCheck:
+		constraintErr.Type = ConstraintCheck
+	case sqlite3.ErrConstraintNotNull:
+		constraintErr.Type = ConstraintNotNull
+	case sqlite3.ErrConstraintForeignKey:
+		constraintErr.Type = ConstraintForeignKey
+	default:
+		return nil
+	}
+
+	// the message ends with the failed constraint, e.g. "UNIQUE constraint failed: users.email"
+	_, detail, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+	detail = strings.TrimSpace(detail)
+
+	switch constraintErr.Type {
+	case ConstraintCheck:
+		constraintErr.Constraint = detail
+	case ConstraintUnique, ConstraintPrimaryKey, ConstraintNotNull:
+		for _, qualified := range strings.Split(detail, ",") {
+			table, column, ok := strings.Cut(strings.TrimSpace(qualified), ".")
+			if !ok {
+				continue
+			}
+			constraintErr.Table = table
+			constraintErr.Columns = append(constraintErr.Columns, column)
+		}
+
+		// primary keys are named by their columns, their automatic index name would not tell the client anything
+		if constraintErr.Type == ConstraintUnique && constraintErr.Table != "" {
+			constraintErr.Constraint = uniqueConstraintName(constraintErr.Table, constraintErr.Columns)
+		}
+	}
+
+	return constraintErr
+}
+
+// uniqueConstraintName finds the unique constraint or index covering exactly the given columns, empty when
+// the schema cannot be read
+func uniqueConstraintName(tableName string, columns []string) string {
+	tableSQL, err := getTableSQL(tableName)
+	if err != nil {
+		return ""
+	}
+
+	constraints, err := parseTableConstraints(tableName, tableSQL)
+	if err != nil {
+		return ""
+	}
+
+	if len(columns) == 1 {
+		if name, ok := constraints.ColumnUniques[columns[0]]; ok && name != "" {
+			return name
+		}
+	}
+
+	for _, unique := range constraints.Uniques {
+		if unique.Name != "" && sameColumns(unique.Columns, columns) {
+			return unique.Name
+		}
+	}
+
+	// unique indexes and unnamed constraints are reported by their index name
+	indexes, err := GetTableIndexes(tableName)
+	if err != nil {
+		return ""
+	}
+
+	for _, index := range indexes {
+		if !index.Unique || len(index.Expressions) > 0 {
+			continue
+		}
+
+		var indexColumns []string
+		for _, column := range index.Columns {
+			indexColumns = append(indexColumns, strings.TrimSuffix(column, " DESC"))
+		}
+		if sameColumns(indexColumns, columns) {
+			return index.Name
+		}
+	}
+
+	return ""
+}
+
+// sameColumns reports whether both lists hold the same column names in any order
+func sameColumns(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]bool)
+	for _, column := range a {
+		set[column] = true
+	}
+	for _, column := range b {
+		if !set[column] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package functions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestValidateColumnExpression(t *testing.T) {
+	columns := map[string]bool{"price": true, "name": true, "starts_at": true, "ends_at": true}
+
+	accepted := []string{
+		"price > 0",
+		"price BETWEEN 0 AND 1e6 OR price IS NULL",
+		"length(trim(name)) > 0 AND name NOT LIKE '%admin%'",
+		"name = 'it''s'",
+		"name != '; DROP TABLE users; --'",
+		"name != '/* not a comment */'",
+		"ends_at IS NULL OR julianday(ends_at) >= julianday(starts_at)",
+		"CASE WHEN price > 10 THEN name IS NOT NULL ELSE 1 END",
+		"CAST(price AS INTEGER) % 2 = 0",
+		"name COLLATE NOCASE IN ('a', 'b')",
+		"-.5 <= price",
+	}
+	for _, expression := range accepted {
+		if err := validateCheckExpression(expression, columns); err != nil {
+			t.Errorf("%s: unexpected error %v", expression, err)
+		}
+	}
+
+	rejected := []struct {
+		expression string
+		want       string
+	}{
+		{"", "cannot be empty"},
+		{"price IN (SELECT price FROM prices)", "unknown column SELECT"},
+		{"EXISTS (SELECT 1)", "function EXISTS is not allowed"},
+		{"price > 0; DROP TABLE users", "unexpected character ';'"},
+		{"price > 0 -- trailing", "comments are not allowed"},
+		{"price > 0 /* block */", "comments are not allowed"},
+		{"random() > 0", "function random is not allowed"},
+		{"load_extension ('evil')", "function load_extension is not allowed"},
+		{"cost > 0", "unknown column cost"},
+		{`"price" > 0`, "unexpected character '\"'"},
+		{"`price` > 0", "unexpected character '`'"},
+		{"[price] > 0", "unexpected character '['"},
+		{"price > ?", "unexpected character '?'"},
+		{"name = 'open", "unterminated string"},
+		{"name = 'it''s", "unterminated string"},
+		{"name = 'a'' OR 1", "unterminated string"},
+		{"(price > 0", "unbalanced parentheses"},
+		{"price > 0)", "unbalanced parentheses"},
+	}
+	for _, tt := range rejected {
+		err := validateCheckExpression(tt.expression, columns)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.expression, err, tt.want)
+		}
+	}
+}
+
+func TestAsConstraintErrorNamesTheConstraint(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "accounts",
+		KeyStrategy: "autoincrement",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "email", DataType: "txt", Unique: true},
+			{Name: "team", DataType: "txt"},
+			{Name: "handle", DataType: "txt"},
+			{Name: "balance", DataType: "int", Check: "balance >= 0"},
+		},
+		Uniques: []models.UniqueConstraintModel{{Name: "team_handle", Columns: []string{"team", "handle"}}},
+		Checks:  []models.CheckConstraintModel{{Name: "handle_length", Expression: "length(handle) > 2"}},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	mustExec(t, "INSERT INTO accounts (email, team, handle, balance) VALUES ('a@x.io', 'reds', 'ann', 0)")
+
+	tests := []struct {
+		name       string
+		query      string
+		kind       string
+		constraint string
+	}{
+		{"column unique", "INSERT INTO accounts (email, team, handle, balance) VALUES ('a@x.io', 'blues', 'bob', 0)", ConstraintUnique, "email_unique"},
+		{"table unique", "INSERT INTO accounts (email, team, handle, balance) VALUES ('b@x.io', 'reds', 'ann', 0)", ConstraintUnique, "team_handle"},
+		{"column check", "INSERT INTO accounts (email, team, handle, balance) VALUES ('b@x.io', 'reds', 'bob', -1)", ConstraintCheck, "balance_check"},
+		{"table check", "INSERT INTO accounts (email, team, handle, balance) VALUES ('b@x.io', 'reds', 'bo', 0)", ConstraintCheck, "handle_length"},
+		{"not null", "INSERT INTO accounts (email, team, handle) VALUES ('b@x.io', 'reds', 'bob')", ConstraintNotNull, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dbclass.DB.Exec(tt.query)
+			constraintErr := AsConstraintError(err)
+			if constraintErr == nil {
+				t.Fatalf("got %v, want a constraint error", err)
+			}
+			if constraintErr.Type != tt.kind || constraintErr.Constraint != tt.constraint {
+				t.Fatalf("got %s constraint %q, want %s constraint %q", constraintErr.Type, constraintErr.Constraint, tt.kind, tt.constraint)
+			}
+		})
+	}
+
+	if AsConstraintError(nil) != nil || AsConstraintError(errors.New("disk full")) != nil {
+		t.Fatalf("only constraint errors should be described")
+	}
+}
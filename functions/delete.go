@@ -0,0 +1,76 @@
+package functions
+
+import (
+	"fmt"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// DeleteFromTable deletes the rows matching the filters and returns the number of deleted rows,
+// the deleted rows themselves are only returned when deleteModel.Returning is set
+func DeleteFromTable(deleteModel models.DeleteModel) (int64, []map[string]any, error) {
+	return deleteFromTable(dbclass.DB, deleteModel)
+}
+
+func deleteFromTable(db dbclass.Querier, deleteModel models.DeleteModel) (int64, []map[string]any, error) {
+	if err := ValidateTableName(deleteModel.TableName); err != nil {
+		return 0, nil, err
+	}
+
+	whereClause, params, err := BuildWhere(deleteModel.TableName, deleteModel.Filters, deleteModel.Where)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if whereClause == "" && !deleteModel.AllowAll {
+		return 0, nil, fmt.Errorf("delete without filters will remove every row, set allow_all to true if this is intended")
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s", deleteModel.TableName)
+	if whereClause != "" {
+		query += " WHERE " + whereClause
+	}
+
+	if deleteModel.Returning {
+		query += " RETURNING *"
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if !deleteModel.Returning {
+		result, err := stmt.Exec(params...)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to execute statement: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+
+		return affected, nil, nil
+	}
+
+	jsonColumns, err := jsonColumnsOf(deleteModel.TableName)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := stmt.Query(params...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to execute statement: %w", err)
+	}
+	defer rows.Close()
+
+	deleted, err := scanRows(rows, jsonColumns)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return int64(len(deleted)), deleted, nil
+}
@@ -0,0 +1,178 @@
+package functions
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/MultiX0/db-test/utils"
+)
+
+const (
+	BlobEncodingBase64 = "base64"
+	BlobEncodingHex    = "hex"
+)
+
+// BlobValue is how BLOB values are written to JSON, the $type marker tells clients it is not plain text
+type BlobValue struct {
+	Type     string `json:"$type"` // always "blob"
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+}
+
+// RowEncoder turns scanned SQLite values into JSON friendly values based on their storage class and the declared column type,
+// NULL stays null, integers and reals keep their type, booleans and timestamps are decoded, JSON is nested and blobs are wrapped in BlobValue
+type RowEncoder struct {
+	columns      []string
+	declTypes    []string
+	jsonColumns  []bool // json columns and the results of the -> operator, their text is returned as nested JSON
+	blobEncoding string
+}
+
+// BlobEncoding returns the configured BLOB_ENCODING (base64 or hex), base64 by default
+func BlobEncoding() string {
+	if strings.ToLower(utils.GetEnv("BLOB_ENCODING", BlobEncodingBase64)) == BlobEncodingHex {
+		return BlobEncodingHex
+	}
+	return BlobEncodingBase64
+}
+
+func NewRowEncoder(rows *sql.Rows) (*RowEncoder, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	encoder := &RowEncoder{
+		columns:      make([]string, len(columnTypes)),
+		declTypes:    make([]string, len(columnTypes)),
+		jsonColumns:  make([]bool, len(columnTypes)),
+		blobEncoding: BlobEncoding(),
+	}
+
+	for i, columnType := range columnTypes {
+		encoder.columns[i] = columnType.Name()
+		encoder.declTypes[i] = strings.ToUpper(columnType.DatabaseTypeName())
+		// json columns of older tables were declared as JSON, newer ones are TEXT and marked with WithJSONColumns
+		encoder.jsonColumns[i] = encoder.declTypes[i] == "JSON" || isJSONSelection(columnType.Name())
+	}
+
+	return encoder, nil
+}
+
+// WithJSONColumns marks the result columns named after json table columns, their text is returned as nested JSON
+func (e *RowEncoder) WithJSONColumns(jsonColumns map[string]bool) *RowEncoder {
+	for i, column := range e.columns {
+		if jsonColumns[column] {
+			e.jsonColumns[i] = true
+		}
+	}
+	return e
+}
+
+func (e *RowEncoder) Columns() []string {
+	return e.columns
+}
+
+// ScanRaw scans the current row and returns the values as the driver returned them, before any encoding
+func (e *RowEncoder) ScanRaw(rows *sql.Rows) ([]any, error) {
+	values := make([]any, len(e.columns))
+	scanArgs := make([]any, len(e.columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	if err := rows.Scan(scanArgs...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	return values, nil
+}
+
+// ScanRow scans the current row and returns its encoded values in column order
+func (e *RowEncoder) ScanRow(rows *sql.Rows) ([]any, error) {
+	values, err := e.ScanRaw(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		values[i] = e.EncodeValue(i, value)
+	}
+
+	return values, nil
+}
+
+// ScanMap scans the current row into a column name -> encoded value map
+func (e *RowEncoder) ScanMap(rows *sql.Rows) (map[string]any, error) {
+	values, err := e.ScanRow(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rowMap := make(map[string]any, len(values))
+	for i, column := range e.columns {
+		rowMap[column] = values[i]
+	}
+
+	return rowMap, nil
+}
+
+// EncodeValue encodes the value of the column at index i
+func (e *RowEncoder) EncodeValue(i int, value any) any {
+	declType := e.declTypes[i]
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case int64:
+		if isBoolType(declType) {
+			return v != 0
+		}
+		return v
+	case float64:
+		// JSON has no infinity, SQLite stores NaN as NULL already
+		if math.IsInf(v, 1) {
+			return "Infinity"
+		}
+		if math.IsInf(v, -1) {
+			return "-Infinity"
+		}
+		if math.IsNaN(v) {
+			return nil
+		}
+		return v
+	case bool:
+		return v
+	case time.Time:
+		if declType == "DATE" {
+			return v.UTC().Format("2006-01-02")
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return e.encodeBlob(v)
+	case string:
+		if e.jsonColumns[i] && json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (e *RowEncoder) encodeBlob(data []byte) BlobValue {
+	if e.blobEncoding == BlobEncodingHex {
+		return BlobValue{Type: "blob", Encoding: BlobEncodingHex, Data: hex.EncodeToString(data)}
+	}
+	return BlobValue{Type: "blob", Encoding: BlobEncodingBase64, Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+func isBoolType(declType string) bool {
+	return declType == "BOOL" || declType == "BOOLEAN"
+}
@@ -0,0 +1,107 @@
+package functions
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	dbclass "github.com/MultiX0/db-test/db"
+)
+
+func TestRowEncoderEncodeValue(t *testing.T) {
+	moment := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("plus2", 2*60*60))
+
+	tests := []struct {
+		name         string
+		declType     string
+		jsonColumn   bool
+		blobEncoding string
+		value        any
+		want         any
+	}{
+		{"null", "TEXT", false, BlobEncodingBase64, nil, nil},
+		{"null bool", "BOOLEAN", false, BlobEncodingBase64, nil, nil},
+		{"integer", "INTEGER", false, BlobEncodingBase64, int64(42), int64(42)},
+		{"big integer", "INTEGER", false, BlobEncodingBase64, int64(math.MaxInt64), int64(math.MaxInt64)},
+		{"real", "REAL", false, BlobEncodingBase64, 1.5, 1.5},
+		{"positive infinity", "REAL", false, BlobEncodingBase64, math.Inf(1), "Infinity"},
+		{"negative infinity", "REAL", false, BlobEncodingBase64, math.Inf(-1), "-Infinity"},
+		{"NaN", "REAL", false, BlobEncodingBase64, math.NaN(), nil},
+		{"bool true", "BOOL", false, BlobEncodingBase64, int64(1), true},
+		{"boolean false", "BOOLEAN", false, BlobEncodingBase64, int64(0), false},
+		{"int in a text column stays int", "TEXT", false, BlobEncodingBase64, int64(1), int64(1)},
+		{"driver bool", "BOOLEAN", false, BlobEncodingBase64, true, true},
+		{"datetime", "DATETIME", false, BlobEncodingBase64, moment, "2024-01-02T13:04:05Z"},
+		{"timestamp", "TIMESTAMP", false, BlobEncodingBase64, moment.Add(500 * time.Millisecond), "2024-01-02T13:04:05.5Z"},
+		{"date", "DATE", false, BlobEncodingBase64, moment, "2024-01-02"},
+		{"text", "TEXT", false, BlobEncodingBase64, `{"a":1}`, `{"a":1}`},
+		{"blob base64", "BLOB", false, BlobEncodingBase64, []byte{0xde, 0xad, 0xbe, 0xef}, BlobValue{Type: "blob", Encoding: "base64", Data: "3q2+7w=="}},
+		{"blob hex", "BLOB", false, BlobEncodingHex, []byte{0xde, 0xad, 0xbe, 0xef}, BlobValue{Type: "blob", Encoding: "hex", Data: "deadbeef"}},
+		{"json object", "TEXT", true, BlobEncodingBase64, `{"a":[1,2]}`, json.RawMessage(`{"a":[1,2]}`)},
+		{"json scalar", "TEXT", true, BlobEncodingBase64, `12345678901234567890`, json.RawMessage(`12345678901234567890`)},
+		{"invalid json stays text", "TEXT", true, BlobEncodingBase64, `{oops`, `{oops`},
+		{"legacy JSON declared type", "JSON", true, BlobEncodingBase64, `[1]`, json.RawMessage(`[1]`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := &RowEncoder{
+				columns:      []string{"value"},
+				declTypes:    []string{tt.declType},
+				jsonColumns:  []bool{tt.jsonColumn},
+				blobEncoding: tt.blobEncoding,
+			}
+
+			got := encoder.EncodeValue(0, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("EncodeValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowEncoderBlobMarshalsWithTypeMarker(t *testing.T) {
+	encoder := &RowEncoder{columns: []string{"data"}, declTypes: []string{"BLOB"}, jsonColumns: []bool{false}, blobEncoding: BlobEncodingHex}
+
+	data, err := json.Marshal(encoder.EncodeValue(0, []byte("hi")))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"$type":"blob","encoding":"hex","data":"6869"}`
+	if string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+}
+
+func TestRowEncoderScansDeclaredTypes(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE typed (n INTEGER, r REAL, b BOOLEAN, d DATETIME, raw BLOB, doc TEXT, note TEXT)")
+	mustExec(t, `INSERT INTO typed VALUES (7, 2.5, 1, '2024-01-02 03:04:05', x'0102', '{"k":"v"}', '{"k":"v"}')`)
+
+	rows, err := dbclass.DB.Query("SELECT * FROM typed")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows, map[string]bool{"doc": true})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	want := map[string]any{
+		"n":    int64(7),
+		"r":    2.5,
+		"b":    true,
+		"d":    "2024-01-02T03:04:05Z",
+		"raw":  BlobValue{Type: "blob", Encoding: BlobEncodingBase64, Data: "AQI="},
+		"doc":  json.RawMessage(`{"k":"v"}`),
+		"note": `{"k":"v"}`,
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0], want) {
+		t.Fatalf("got %#v, want %#v", results, want)
+	}
+}
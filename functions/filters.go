@@ -0,0 +1,354 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+// MaxFilterDepth limits how deeply and/or/not nodes can be nested in a filter expression
+const MaxFilterDepth = 16
+
+// jsonPathPattern accepts simple JSON paths like $.a.b[0].c
+var jsonPathPattern = regexp.MustCompile(`^\$(\.[a-zA-Z_][a-zA-Z0-9_]*|\[[0-9]+\])*$`)
+
+// likeEscaper escapes the LIKE wildcards so starts_with/ends_with/contains match the value literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func ValidateOperator(operator string) error {
+	validOps := map[string]bool{
+		"eq": true, "ne": true, "gt": true, "lt": true,
+		"gte": true, "lte": true, "like": true, "in": true, "not_in": true,
+		"is_null": true, "is_not_null": true,
+		"ilike": true, "between": true, "starts_with": true, "ends_with": true,
+		"contains": true, "array_contains": true, "regex": true, "is_distinct_from": true,
+	}
+
+	if !validOps[operator] {
+		return fmt.Errorf("invalid operator: %s", operator)
+	}
+	return nil
+}
+
+// ValidateJSONPath checks that a filter path is a plain JSON path so it can be passed to json_extract
+func ValidateJSONPath(path string) error {
+	if !jsonPathPattern.MatchString(path) {
+		return fmt.Errorf("invalid json path: %s", path)
+	}
+	return nil
+}
+
+// getColumnSet returns the set of column names of a table, used to validate filter columns
+func getColumnSet(tableName string) (map[string]bool, error) {
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table columns: %w", err)
+	}
+
+	actualColumnSet := make(map[string]bool)
+	for _, col := range *columnsPtr {
+		actualColumnSet[col.Name] = true
+	}
+
+	return actualColumnSet, nil
+}
+
+// BuildWhere combines the flat filter groups and the filter expression tree of a request with AND
+func BuildWhere(tableName string, filters []models.FilterGroup, where *models.FilterExpression) (string, []any, error) {
+	whereClause, params, err := BuildWhereClause(tableName, filters)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if where == nil {
+		return whereClause, params, nil
+	}
+
+	expressionClause, expressionParams, err := BuildFilterExpression(tableName, *where)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if whereClause == "" {
+		return expressionClause, expressionParams, nil
+	}
+
+	return whereClause + " AND " + expressionClause, append(params, expressionParams...), nil
+}
+
+func BuildWhereClause(tableName string, filters []models.FilterGroup) (string, []any, error) {
+	if len(filters) == 0 {
+		return "", []any{}, nil
+	}
+
+	actualColumnSet, err := getColumnSet(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var whereParts []string
+	var params []any
+
+	for _, group := range filters {
+		if len(group.Conditions) == 0 {
+			continue
+		}
+
+		logic := strings.ToUpper(group.Logic)
+		if logic != "AND" && logic != "OR" {
+			logic = "AND"
+		}
+
+		var conditionParts []string
+
+		for _, condition := range group.Conditions {
+			conditionSQL, conditionParams, err := buildValidatedCondition(tableName, actualColumnSet, condition)
+			if err != nil {
+				return "", nil, err
+			}
+
+			conditionParts = append(conditionParts, conditionSQL)
+			params = append(params, conditionParams...)
+		}
+
+		if len(conditionParts) > 0 {
+			groupClause := "(" + strings.Join(conditionParts, " "+logic+" ") + ")"
+			whereParts = append(whereParts, groupClause)
+		}
+	}
+
+	whereClause := strings.Join(whereParts, " AND ")
+	return whereClause, params, nil
+}
+
+// BuildFilterExpression compiles a filter tree like (a OR b) AND NOT (c AND d) into a parenthesized SQL condition
+func BuildFilterExpression(tableName string, expression models.FilterExpression) (string, []any, error) {
+	actualColumnSet, err := getColumnSet(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return buildExpressionNode(expression, 1, func(condition models.FilterCondition) (string, []any, error) {
+		return buildValidatedCondition(tableName, actualColumnSet, condition)
+	})
+}
+
+// conditionBuilder turns a leaf of a filter expression into SQL, WHERE and HAVING resolve columns differently
+type conditionBuilder func(condition models.FilterCondition) (string, []any, error)
+
+func buildExpressionNode(node models.FilterExpression, depth int, buildLeaf conditionBuilder) (string, []any, error) {
+	if depth > MaxFilterDepth {
+		return "", nil, fmt.Errorf("filter expression is nested deeper than %d levels", MaxFilterDepth)
+	}
+
+	kinds := 0
+	if node.And != nil {
+		kinds++
+	}
+	if node.Or != nil {
+		kinds++
+	}
+	if node.Not != nil {
+		kinds++
+	}
+	if node.Column != "" || node.Operator != "" {
+		kinds++
+	}
+
+	if kinds != 1 {
+		return "", nil, fmt.Errorf("each filter expression node should have exactly one of and, or, not or a condition")
+	}
+
+	switch {
+	case node.And != nil || node.Or != nil:
+		children, logic := node.And, "AND"
+		if node.Or != nil {
+			children, logic = node.Or, "OR"
+		}
+
+		if len(children) == 0 {
+			return "", nil, fmt.Errorf("%s filter expression requires at least one child", strings.ToLower(logic))
+		}
+
+		var parts []string
+		var params []any
+		for _, child := range children {
+			childSQL, childParams, err := buildExpressionNode(child, depth+1, buildLeaf)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, childSQL)
+			params = append(params, childParams...)
+		}
+
+		return "(" + strings.Join(parts, " "+logic+" ") + ")", params, nil
+	case node.Not != nil:
+		childSQL, childParams, err := buildExpressionNode(*node.Not, depth+1, buildLeaf)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(NOT " + childSQL + ")", childParams, nil
+	default:
+		conditionSQL, conditionParams, err := buildLeaf(node.FilterCondition)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + conditionSQL + ")", conditionParams, nil
+	}
+}
+
+// buildValidatedCondition checks the column and operator of a condition before building it
+func buildValidatedCondition(tableName string, columnSet map[string]bool, condition models.FilterCondition) (string, []any, error) {
+	// a column like meta->address.city is the same as filtering meta with the path $.address.city
+	column, path, found, err := splitJSONSelector(condition.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	if found {
+		if condition.Path != "" {
+			return "", nil, fmt.Errorf("filter column '%s' already has a json path, do not set path as well", condition.Column)
+		}
+		condition.Column, condition.Path = column, path
+	}
+
+	if err := ValidateColumnName(condition.Column); err != nil {
+		return "", nil, err
+	}
+
+	if !columnSet[condition.Column] {
+		return "", nil, fmt.Errorf("filter column '%s' does not exist in table '%s'", condition.Column, tableName)
+	}
+
+	if err := ValidateOperator(condition.Operator); err != nil {
+		return "", nil, err
+	}
+
+	if condition.Path != "" {
+		if err := ValidateJSONPath(condition.Path); err != nil {
+			return "", nil, err
+		}
+		return buildCondition(fmt.Sprintf("json_extract(%s, ?)", condition.Column), []any{condition.Path}, condition.Operator, condition.Value)
+	}
+
+	return buildCondition(condition.Column, nil, condition.Operator, condition.Value)
+}
+
+// buildCondition builds the SQL of one condition on the target expression, the target params are
+// the placeholders used by the target itself (the json path) and always come before the value params
+func buildCondition(target string, targetParams []any, operator string, value any) (string, []any, error) {
+	params := append([]any{}, targetParams...)
+
+	switch operator {
+	case "eq":
+		return fmt.Sprintf("%s = ?", target), append(params, value), nil
+	case "ne":
+		return fmt.Sprintf("%s != ?", target), append(params, value), nil
+	case "gt":
+		return fmt.Sprintf("%s > ?", target), append(params, value), nil
+	case "lt":
+		return fmt.Sprintf("%s < ?", target), append(params, value), nil
+	case "gte":
+		return fmt.Sprintf("%s >= ?", target), append(params, value), nil
+	case "lte":
+		return fmt.Sprintf("%s <= ?", target), append(params, value), nil
+	case "is_distinct_from":
+		return fmt.Sprintf("%s IS NOT ?", target), append(params, value), nil
+	case "like":
+		return fmt.Sprintf("%s LIKE ?", target), append(params, value), nil
+	case "ilike":
+		pattern, ok := value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("ILIKE operator requires a string value")
+		}
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", target), append(params, pattern), nil
+	case "starts_with", "ends_with", "contains":
+		text, ok := value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%s operator requires a string value", operator)
+		}
+
+		pattern := likeEscaper.Replace(text)
+		switch operator {
+		case "starts_with":
+			pattern = pattern + "%"
+		case "ends_with":
+			pattern = "%" + pattern
+		default:
+			pattern = "%" + pattern + "%"
+		}
+
+		return fmt.Sprintf("%s LIKE ? ESCAPE '\\'", target), append(params, pattern), nil
+	case "array_contains":
+		// the target is a JSON array, anything else contains nothing. CASE keeps json_each away from text
+		// that is not JSON, which would fail the whole query
+		if !isScalarValue(value) {
+			return "", nil, fmt.Errorf("ARRAY_CONTAINS operator requires a number, string or boolean value")
+		}
+		params = append(params, targetParams...)
+		condition := fmt.Sprintf("CASE WHEN json_valid(%s) THEN EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = ?) ELSE 0 END", target, target)
+		return condition, append(params, value), nil
+	case "regex":
+		pattern, ok := value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("REGEX operator requires a string pattern")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", nil, fmt.Errorf("invalid regex pattern: %v", err)
+		}
+		return fmt.Sprintf("%s REGEXP ?", target), append(params, pattern), nil
+	case "between":
+		values, ok := value.([]any)
+		if !ok || len(values) != 2 {
+			return "", nil, fmt.Errorf("BETWEEN operator requires an array of exactly two values")
+		}
+		for _, bound := range values {
+			if !isScalarValue(bound) {
+				return "", nil, fmt.Errorf("BETWEEN operator bounds should be numbers or strings")
+			}
+		}
+		return fmt.Sprintf("%s BETWEEN ? AND ?", target), append(params, values...), nil
+	case "is_null":
+		return fmt.Sprintf("%s IS NULL", target), params, nil
+	case "is_not_null":
+		return fmt.Sprintf("%s IS NOT NULL", target), params, nil
+	case "in":
+		values, ok := value.([]any)
+		if !ok {
+			return "", nil, fmt.Errorf("IN operator requires array of values")
+		}
+		if len(values) == 0 {
+			return "", nil, fmt.Errorf("IN operator requires at least one value")
+		}
+
+		placeholders := strings.Repeat("?,", len(values))
+		placeholders = placeholders[:len(placeholders)-1]
+
+		return fmt.Sprintf("%s IN (%s)", target, placeholders), append(params, values...), nil
+	case "not_in":
+		values, ok := value.([]any)
+		if !ok {
+			return "", nil, fmt.Errorf("NOT IN operator requires array of values")
+		}
+		if len(values) == 0 {
+			return "", nil, fmt.Errorf("NOT IN operator requires at least one value")
+		}
+
+		placeholders := strings.Repeat("?,", len(values))
+		placeholders = placeholders[:len(placeholders)-1]
+
+		return fmt.Sprintf("%s NOT IN (%s)", target, placeholders), append(params, values...), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported operator: %s", operator)
+	}
+}
+
+func isScalarValue(value any) bool {
+	switch value.(type) {
+	case string, float64, int, int64, bool:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func createItemsTable(t *testing.T) {
+	t.Helper()
+	mustExec(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, meta TEXT, tags TEXT, note TEXT, active BOOLEAN)")
+	mustExec(t, `INSERT INTO items (id, name, price, meta, tags, note, active) VALUES
+		(1, 'Apple', 1.5, '{"color":"red","size":3}', '["a","b"]', 'x', 1),
+		(2, 'apricot', 3, '{"color":"green","size":5}', '["b"]', NULL, 0),
+		(3, '50%_off', 10, '{"color":"red"}', 'not json', 'x', 1),
+		(4, 'banana\split', 7, NULL, '[1,2,true]', 'y', 0)`)
+}
+
+// filterIDs runs a select with a single condition and returns the matching ids in order
+func filterIDs(t *testing.T, condition models.FilterCondition) ([]int64, error) {
+	t.Helper()
+
+	whereClause, params, err := BuildWhere("items", nil, &models.FilterExpression{FilterCondition: condition})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbclass.DB.Query("SELECT id FROM items WHERE "+whereClause+" ORDER BY id", params...)
+	if err != nil {
+		t.Fatalf("query %s: %v", whereClause, err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func TestFilterOperators(t *testing.T) {
+	openTestDB(t)
+	createItemsTable(t)
+
+	tests := []struct {
+		name      string
+		condition models.FilterCondition
+		want      []int64
+	}{
+		{"eq bool", models.FilterCondition{Column: "active", Operator: "eq", Value: true}, []int64{1, 3}},
+		{"between numbers", models.FilterCondition{Column: "price", Operator: "between", Value: []any{2.0, 8.0}}, []int64{2, 4}},
+		{"between strings", models.FilterCondition{Column: "name", Operator: "between", Value: []any{"a", "b"}}, []int64{2}},
+		{"ilike", models.FilterCondition{Column: "name", Operator: "ilike", Value: "ap%"}, []int64{1, 2}},
+		{"starts_with escapes wildcards", models.FilterCondition{Column: "name", Operator: "starts_with", Value: "50%_"}, []int64{3}},
+		{"starts_with underscore is literal", models.FilterCondition{Column: "name", Operator: "starts_with", Value: "5_"}, []int64{}},
+		{"ends_with", models.FilterCondition{Column: "name", Operator: "ends_with", Value: "_off"}, []int64{3}},
+		{"contains percent", models.FilterCondition{Column: "name", Operator: "contains", Value: "%"}, []int64{3}},
+		{"contains backslash", models.FilterCondition{Column: "name", Operator: "contains", Value: `\`}, []int64{4}},
+		{"contains is case sensitive", models.FilterCondition{Column: "name", Operator: "contains", Value: "pp"}, []int64{1}},
+		{"array_contains string", models.FilterCondition{Column: "tags", Operator: "array_contains", Value: "b"}, []int64{1, 2}},
+		{"array_contains number", models.FilterCondition{Column: "tags", Operator: "array_contains", Value: 2}, []int64{4}},
+		{"array_contains bool", models.FilterCondition{Column: "tags", Operator: "array_contains", Value: true}, []int64{4}},
+		{"regex", models.FilterCondition{Column: "name", Operator: "regex", Value: "^[Aa]p"}, []int64{1, 2}},
+		{"regex on numbers", models.FilterCondition{Column: "price", Operator: "regex", Value: `^1`}, []int64{1, 3}},
+		{"json path", models.FilterCondition{Column: "meta", Path: "$.color", Operator: "eq", Value: "red"}, []int64{1, 3}},
+		{"json selector", models.FilterCondition{Column: "meta->size", Operator: "gt", Value: 4}, []int64{2}},
+		{"json path between", models.FilterCondition{Column: "meta", Path: "$.size", Operator: "between", Value: []any{1, 4}}, []int64{1}},
+		{"is_distinct_from includes nulls", models.FilterCondition{Column: "note", Operator: "is_distinct_from", Value: "x"}, []int64{2, 4}},
+		{"is_distinct_from null", models.FilterCondition{Column: "note", Operator: "is_distinct_from", Value: nil}, []int64{1, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := filterIDs(t, tt.condition)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Fatalf("got ids %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterOperatorValidation(t *testing.T) {
+	openTestDB(t)
+	createItemsTable(t)
+
+	tests := []struct {
+		name      string
+		condition models.FilterCondition
+		wantErr   string
+	}{
+		{"unknown operator", models.FilterCondition{Column: "name", Operator: "includes", Value: "a"}, "invalid operator"},
+		{"between needs two values", models.FilterCondition{Column: "price", Operator: "between", Value: []any{1}}, "exactly two values"},
+		{"between needs an array", models.FilterCondition{Column: "price", Operator: "between", Value: 1}, "exactly two values"},
+		{"between bounds are scalars", models.FilterCondition{Column: "price", Operator: "between", Value: []any{1, []any{2}}}, "bounds"},
+		{"ilike needs a string", models.FilterCondition{Column: "name", Operator: "ilike", Value: 1}, "ILIKE operator requires a string"},
+		{"starts_with needs a string", models.FilterCondition{Column: "name", Operator: "starts_with", Value: 1}, "starts_with operator requires a string"},
+		{"ends_with needs a string", models.FilterCondition{Column: "name", Operator: "ends_with", Value: nil}, "ends_with operator requires a string"},
+		{"contains needs a string", models.FilterCondition{Column: "name", Operator: "contains", Value: []any{"a"}}, "contains operator requires a string"},
+		{"array_contains needs a scalar", models.FilterCondition{Column: "tags", Operator: "array_contains", Value: map[string]any{"a": 1}}, "ARRAY_CONTAINS operator requires"},
+		{"regex needs a string", models.FilterCondition{Column: "name", Operator: "regex", Value: 1}, "REGEX operator requires a string"},
+		{"regex must compile", models.FilterCondition{Column: "name", Operator: "regex", Value: "("}, "invalid regex pattern"},
+		{"json path is validated", models.FilterCondition{Column: "meta", Path: "$.a; DROP TABLE items", Operator: "eq", Value: 1}, "invalid json path"},
+		{"json selector and path", models.FilterCondition{Column: "meta->a", Path: "$.b", Operator: "eq", Value: 1}, "already has a json path"},
+		{"unknown column", models.FilterCondition{Column: "missing", Operator: "eq", Value: 1}, "does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := filterIDs(t, tt.condition)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
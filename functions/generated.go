@@ -0,0 +1,111 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+const (
+	GeneratedVirtual = "virtual"
+	GeneratedStored  = "stored"
+)
+
+// generatedPattern finds the start of the expression of a generated column definition, GENERATED ALWAYS is optional in SQLite
+var generatedPattern = regexp.MustCompile(`(?i)\b(?:GENERATED\s+ALWAYS\s+)?AS\s*\(`)
+
+// validateGenerated checks the generated expression of a column and returns it with the mode normalized,
+// columns are the names of the table, a column cannot be computed from itself
+func validateGenerated(column models.ColumnModel, columns map[string]bool) (*models.GeneratedModel, error) {
+	generated := *column.Generated
+
+	generated.Mode = strings.ToLower(strings.TrimSpace(generated.Mode))
+	if generated.Mode == "" {
+		generated.Mode = GeneratedVirtual
+	}
+	if generated.Mode != GeneratedVirtual && generated.Mode != GeneratedStored {
+		return nil, fmt.Errorf("invalid generated mode for column '%s': %s, use virtual or stored", column.Name, column.Generated.Mode)
+	}
+
+	if column.IsPrimaryKey {
+		return nil, fmt.Errorf("column '%s' is part of the primary key and cannot be generated", column.Name)
+	}
+
+	if column.Default_Value != nil && strings.TrimSpace(*column.Default_Value) != "" {
+		return nil, fmt.Errorf("column '%s' is generated and cannot have a default value", column.Name)
+	}
+
+	others := make(map[string]bool)
+	for name := range columns {
+		if name != column.Name {
+			others[name] = true
+		}
+	}
+
+	if err := validateColumnExpression("generated", generated.Expression, others); err != nil {
+		return nil, fmt.Errorf("column '%s': %v", column.Name, err)
+	}
+
+	return &generated, nil
+}
+
+// generatedClause renders the GENERATED ALWAYS AS clause of a validated generated column
+func generatedClause(generated *models.GeneratedModel) string {
+	return fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", generated.Expression, strings.ToUpper(generated.Mode))
+}
+
+// parseGeneratedExpressions reads the expressions of the generated columns out of a CREATE TABLE statement,
+// pragma_table_xinfo only tells which columns are generated
+func parseGeneratedExpressions(tableName string, tableSQL string) (map[string]string, error) {
+	expressions := make(map[string]string)
+	if tableSQL == "" {
+		return expressions, nil
+	}
+
+	body, _, err := splitTableSQL(tableName, tableSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := splitDefinitions(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the schema of table '%s': %v", tableName, err)
+	}
+
+	for _, definition := range definitions {
+		if isTableConstraint(definition) {
+			continue
+		}
+
+		// an AS inside parentheses belongs to something else, like the CAST of a CHECK. Before the AS of the
+		// generated clause every parenthesis is closed, so only the one added here closes the outer level
+		for _, match := range generatedPattern.FindAllStringIndex(definition, -1) {
+			if closingParenthesis(definition[:match[0]]+")") != match[0] {
+				continue
+			}
+
+			rest := definition[match[1]:]
+			end := closingParenthesis(rest)
+			if end < 0 {
+				break
+			}
+
+			expressions[unquoteIdentifier(definitionName(definition))] = strings.TrimSpace(rest[:end])
+			break
+		}
+	}
+
+	return expressions, nil
+}
+
+// validateWritableColumns refuses writes to generated columns, checked are the columns of writeCheckedColumns
+func validateWritableColumns(checked map[string]models.ColumnModel, columns []string) error {
+	for _, column := range columns {
+		if checked[strings.TrimSpace(column)].Generated != nil {
+			return fmt.Errorf("column '%s' is generated from other columns and cannot be written", column)
+		}
+	}
+	return nil
+}
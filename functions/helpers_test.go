@@ -0,0 +1,47 @@
+package functions
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+)
+
+// openTestDB points the package at fresh main and admin databases in a temporary directory
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	db, err := sql.Open(dbclass.DriverName, filepath.Join(dir, "inline.db"))
+	if err != nil {
+		t.Fatalf("open main db: %v", err)
+	}
+	admin, err := sql.Open(dbclass.DriverName, filepath.Join(dir, "admin.db"))
+	if err != nil {
+		t.Fatalf("open admin db: %v", err)
+	}
+
+	previousDB, previousAdmin := dbclass.DB, dbclass.AdminDB
+	dbclass.DB, dbclass.AdminDB = db, admin
+	t.Cleanup(func() {
+		db.Close()
+		admin.Close()
+		dbclass.DB, dbclass.AdminDB = previousDB, previousAdmin
+	})
+
+	if err := dbclass.SetupAdminSchema(); err != nil {
+		t.Fatalf("setup admin schema: %v", err)
+	}
+}
+
+func mustExec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := dbclass.DB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func intPtr(value int) *int {
+	return &value
+}
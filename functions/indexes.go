@@ -0,0 +1,325 @@
+package functions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+var ErrIndexNotFound = errors.New("index does not exist")
+
+// indexPattern splits a CREATE INDEX statement into its uniqueness, index name, table name and the rest after "("
+var indexPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\S+?)\s+ON\s+(\S+?)\s*\((.*)$`)
+
+// indexDirectionPattern finds the ASC or DESC that may follow an indexed expression
+var indexDirectionPattern = regexp.MustCompile(`(?i)\s+(ASC|DESC)\s*$`)
+
+// dbstat is a compile time option of SQLite, whether it is there is checked once
+var (
+	dbstatOnce      sync.Once
+	dbstatAvailable bool
+)
+
+func hasDBStat() bool {
+	dbstatOnce.Do(func() {
+		_, err := dbclass.DB.Exec("SELECT 1 FROM dbstat LIMIT 1")
+		dbstatAvailable = err == nil
+	})
+	return dbstatAvailable
+}
+
+// GetTableIndexes returns every index of a table, including the automatic indexes of UNIQUE and PRIMARY KEY constraints
+func GetTableIndexes(tableName string) ([]models.IndexModel, error) {
+	rows, err := dbclass.DB.Query(`SELECT il.name, il."unique", il.origin, s.sql FROM pragma_index_list(?) il
+		LEFT JOIN sqlite_schema s ON s.name = il.name AND s.type = 'index' ORDER BY il.name`, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []models.IndexModel{}
+	for rows.Next() {
+		var index models.IndexModel
+		var indexSQL sql.NullString
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Origin, &indexSQL); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index.TableName = tableName
+		index.Sql = indexSQL.String
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range indexes {
+		if err := describeIndex(&indexes[i]); err != nil {
+			return nil, fmt.Errorf("failed to read index %s: %v", indexes[i].Name, err)
+		}
+	}
+
+	return indexes, nil
+}
+
+// describeIndex fills the columns, expressions, partial condition and size of an index
+func describeIndex(index *models.IndexModel) error {
+	var terms []string
+	if index.Sql != "" {
+		var err error
+		terms, index.Where, err = splitIndexSQL(index.Sql)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := dbclass.DB.Query("SELECT seqno, name, \"desc\" FROM pragma_index_xinfo(?) WHERE key = 1 ORDER BY seqno", index.Name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index.Columns = []string{}
+	for rows.Next() {
+		var seqno int
+		var name sql.NullString
+		var desc bool
+		if err := rows.Scan(&seqno, &name, &desc); err != nil {
+			return err
+		}
+
+		// expressions have no column name, their text is taken from the CREATE INDEX statement
+		if !name.Valid {
+			if seqno < len(terms) {
+				index.Expressions = append(index.Expressions, terms[seqno])
+			}
+			continue
+		}
+
+		column := name.String
+		if desc {
+			column += " DESC"
+		}
+		index.Columns = append(index.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if hasDBStat() {
+		var size sql.NullInt64
+		err := dbclass.DB.QueryRow("SELECT SUM(pgsize) FROM dbstat WHERE name = ?", index.Name).Scan(&size)
+		if err != nil {
+			return err
+		}
+		index.SizeBytes = &size.Int64
+	}
+
+	return nil
+}
+
+// splitIndexSQL returns the indexed terms of a CREATE INDEX statement and the condition of a partial index
+func splitIndexSQL(indexSQL string) ([]string, string, error) {
+	match := indexPattern.FindStringSubmatch(indexSQL)
+	if match == nil {
+		return nil, "", fmt.Errorf("could not parse the index definition")
+	}
+
+	rest := match[4]
+	end := closingParenthesis(rest)
+	if end < 0 {
+		return nil, "", fmt.Errorf("could not parse the index definition")
+	}
+
+	terms, err := splitDefinitions(rest[:end])
+	if err != nil {
+		return nil, "", err
+	}
+
+	var where string
+	tail := strings.TrimSpace(rest[end+1:])
+	if len(tail) > 5 && strings.EqualFold(tail[:5], "WHERE") {
+		where = strings.TrimSpace(tail[5:])
+	}
+
+	return terms, where, nil
+}
+
+// closingParenthesis returns the position of the ")" closing a parenthesis opened just before s, -1 when there is none
+func closingParenthesis(s string) int {
+	depth := 1
+	var quote rune
+
+	for i, char := range s {
+		if quote != 0 {
+			if char == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch char {
+		case '\'', '"', '`':
+			quote = char
+		case '[':
+			quote = ']'
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// validateIndexExpression checks an indexed expression or the condition of a partial index with the grammar of
+// validateColumnExpression, so only the columns of the table and the allowed functions can be used
+func validateIndexExpression(kind string, expression string, columns map[string]bool) error {
+	if kind == "index" {
+		expression = indexDirectionPattern.ReplaceAllString(expression, "")
+	}
+
+	if err := validateColumnExpression(kind, expression, columns); err != nil {
+		return err
+	}
+
+	parts, err := splitDefinitions(expression)
+	if err != nil {
+		return fmt.Errorf("invalid %s expression %s: %v", kind, expression, err)
+	}
+	if len(parts) != 1 {
+		return fmt.Errorf("invalid %s expression %s: give one expression per entry", kind, expression)
+	}
+
+	return nil
+}
+
+// CreateIndex creates a secondary index, columns may end with ASC or DESC, expressions index computed values and
+// where makes the index partial. An index without a name is named idx_<table>_<columns>
+func CreateIndex(index models.IndexModel) (*models.IndexModel, error) {
+	tableName := index.TableName
+	if err := ValidateTableName(tableName); err != nil {
+		return nil, err
+	}
+
+	if len(index.Columns) == 0 && len(index.Expressions) == 0 {
+		return nil, fmt.Errorf("an index needs at least one column or expression")
+	}
+
+	columnSet, err := getColumnSet(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []string
+	nameParts := []string{"idx", tableName}
+	for _, column := range index.Columns {
+		fields := strings.Fields(column)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid index column: %s, use the column name optionally followed by ASC or DESC", column)
+		}
+
+		if !columnSet[fields[0]] {
+			return nil, fmt.Errorf("column '%s' does not exist in table '%s'", fields[0], tableName)
+		}
+
+		term := fields[0]
+		if len(fields) == 2 {
+			direction := strings.ToUpper(fields[1])
+			if direction != "ASC" && direction != "DESC" {
+				return nil, fmt.Errorf("invalid index column: %s, use the column name optionally followed by ASC or DESC", column)
+			}
+			term += " " + direction
+		}
+
+		terms = append(terms, term)
+		nameParts = append(nameParts, fields[0])
+	}
+
+	for _, expression := range index.Expressions {
+		if err := validateIndexExpression("index", expression, columnSet); err != nil {
+			return nil, err
+		}
+		terms = append(terms, expression)
+	}
+	if len(index.Expressions) > 0 {
+		nameParts = append(nameParts, "expr")
+	}
+
+	name := index.Name
+	if name == "" {
+		name = strings.Join(nameParts, "_")
+	}
+	if err := validateNewName("index", name); err != nil {
+		return nil, err
+	}
+
+	statement := "CREATE INDEX"
+	if index.Unique {
+		statement = "CREATE UNIQUE INDEX"
+	}
+	statement = fmt.Sprintf("%s %s ON %s (%s)", statement, name, tableName, strings.Join(terms, ", "))
+
+	if strings.TrimSpace(index.Where) != "" {
+		if err := validateIndexExpression("index where", index.Where, columnSet); err != nil {
+			return nil, err
+		}
+		statement += " WHERE " + index.Where
+	}
+
+	// SQLite rejects unknown functions, non deterministic expressions and existing duplicates of a unique index
+	if err := execSchemaStatements(statement); err != nil {
+		return nil, err
+	}
+
+	return GetIndex(name)
+}
+
+// GetIndex returns one index by name
+func GetIndex(name string) (*models.IndexModel, error) {
+	var tableName string
+	err := dbclass.DB.QueryRow("SELECT tbl_name FROM sqlite_schema WHERE name = ? AND type = 'index'", name).Scan(&tableName)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := GetTableIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		if index.Name == name {
+			return &index, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, name)
+}
+
+// DropIndex drops an index created with CREATE INDEX, the indexes of constraints go away with their constraint
+func DropIndex(name string) error {
+	index, err := GetIndex(name)
+	if err != nil {
+		return err
+	}
+
+	if index.Origin != "c" {
+		return fmt.Errorf("index '%s' belongs to a UNIQUE or PRIMARY KEY constraint of table '%s' and cannot be dropped", name, index.TableName)
+	}
+
+	return execSchemaStatements(fmt.Sprintf("DROP INDEX %s", name))
+}
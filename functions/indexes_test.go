@@ -0,0 +1,53 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestCreateIndexValidatesExpressions(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, meta TEXT, deleted_at DATETIME)")
+	mustExec(t, "CREATE TABLE secrets (value TEXT)")
+
+	tests := []struct {
+		name    string
+		index   models.IndexModel
+		wantErr string
+	}{
+		{"expression", models.IndexModel{Name: "idx_email_lower", Expressions: []string{"lower(email)"}}, ""},
+		{"expression with direction", models.IndexModel{Name: "idx_email_desc", Expressions: []string{"lower(email) DESC"}}, ""},
+		{"json path expression", models.IndexModel{Name: "idx_meta_city", Expressions: []string{"json_extract(meta, '$.city')"}}, ""},
+		{"partial", models.IndexModel{Name: "idx_live_email", Columns: []string{"email"}, Where: "deleted_at IS NULL"}, ""},
+		{"subquery expression", models.IndexModel{Name: "idx_bad1", Expressions: []string{"(SELECT value FROM secrets)"}}, "unknown column"},
+		{"unknown function", models.IndexModel{Name: "idx_bad2", Expressions: []string{"load_extension(email)"}}, "function load_extension is not allowed"},
+		{"unknown column", models.IndexModel{Name: "idx_bad3", Expressions: []string{"lower(password)"}}, "unknown column password"},
+		{"two expressions in one entry", models.IndexModel{Name: "idx_bad4", Expressions: []string{"lower(email), upper(email)"}}, "one expression per entry"},
+		{"comment", models.IndexModel{Name: "idx_bad5", Expressions: []string{"email /* x */"}}, "comments are not allowed"},
+		{"statement end", models.IndexModel{Name: "idx_bad6", Expressions: []string{"email); DROP TABLE users; --"}}, "invalid index expression"},
+		{"subquery in where", models.IndexModel{Name: "idx_bad7", Columns: []string{"email"}, Where: "email IN (SELECT value FROM secrets)"}, "invalid index where expression"},
+		{"quoted identifier in where", models.IndexModel{Name: "idx_bad8", Columns: []string{"email"}, Where: `"email" IS NULL`}, "invalid index where expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.index.TableName = "users"
+			created, err := CreateIndex(tt.index)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("create index: %v", err)
+				}
+				if created.Name != tt.index.Name {
+					t.Fatalf("created %s, want %s", created.Name, tt.index.Name)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
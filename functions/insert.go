@@ -0,0 +1,365 @@
+package functions
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func InsertIntoTable(insertModel models.InsertModel) (*models.InsertResult, error) {
+	return insertIntoTable(dbclass.DB, insertModel)
+}
+
+func insertIntoTable(db dbclass.Querier, insertModel models.InsertModel) (*models.InsertResult, error) {
+	if len(insertModel.Columns) != len(insertModel.Values) {
+		return nil, fmt.Errorf("insert request has %d columns but %d values", len(insertModel.Columns), len(insertModel.Values))
+	}
+
+	// an upsert looks for the conflicting row before it writes, both have to see the same data so a single upsert
+	// runs in its own transaction. A concurrent write then fails the upsert instead of changing the reported status
+	if conn, ok := db.(*sql.DB); ok && insertModel.OnConflict != nil {
+		tx, err := conn.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+
+		result, err := insertIntoTable(tx, insertModel)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return result, nil
+	}
+
+	statement, err := buildInsertStatement(insertModel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the statement
+	stmt, err := db.Prepare(statement.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	return insertRow(db, stmt, insertModel, statement, insertModel.Values)
+}
+
+// BulkInsertIntoTable inserts every row of insertModel.Rows with one prepared statement inside a single transaction,
+// the first failing row rolls back the whole insert unless ContinueOnError is set, in that case failed rows are reported and skipped
+func BulkInsertIntoTable(insertModel models.InsertModel) (*models.BulkInsertResult, error) {
+	tx, err := dbclass.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := bulkInsertIntoTable(tx, insertModel)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return result, nil
+}
+
+// bulkInsertIntoTable runs a bulk insert inside the caller's transaction
+func bulkInsertIntoTable(tx dbclass.Querier, insertModel models.InsertModel) (*models.BulkInsertResult, error) {
+	if len(insertModel.Rows) == 0 {
+		return nil, fmt.Errorf("bulk insert requires at least one row")
+	}
+
+	columns, rows, err := normalizeInsertRows(insertModel.Columns, insertModel.Rows)
+	if err != nil {
+		return nil, err
+	}
+	insertModel.Columns = columns
+
+	statement, err := buildInsertStatement(insertModel)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.Prepare(statement.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	result := &models.BulkInsertResult{
+		IDs:     make([]any, len(rows)),
+		Results: make([]models.InsertResult, len(rows)),
+		Errors:  []models.InsertRowError{},
+	}
+
+	for i, values := range rows {
+		rowResult, err := insertRow(tx, stmt, insertModel, statement, values)
+		if err != nil {
+			if !insertModel.ContinueOnError {
+				return nil, fmt.Errorf("row %d: %w", i, err)
+			}
+
+			result.Results[i] = models.InsertResult{Status: InsertStatusFailed}
+			result.Errors = append(result.Errors, models.InsertRowError{Index: i, Error: err.Error()})
+			continue
+		}
+
+		result.IDs[i] = rowResult.ID
+		result.Results[i] = *rowResult
+	}
+
+	return result, nil
+}
+
+// normalizeInsertRows turns the rows of a bulk insert into value slices aligned to the returned columns,
+// object rows use the given columns or the sorted keys of the first row, missing keys are inserted as NULL
+func normalizeInsertRows(columns []string, rawRows []any) ([]string, [][]any, error) {
+	if len(columns) == 0 {
+		first, ok := rawRows[0].(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("bulk insert with array rows requires the columns")
+		}
+
+		for column := range first {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+	}
+
+	columnSet := make(map[string]bool)
+	for _, column := range columns {
+		columnSet[column] = true
+	}
+
+	rows := make([][]any, len(rawRows))
+	for i, rawRow := range rawRows {
+		switch row := rawRow.(type) {
+		case []any:
+			if len(row) != len(columns) {
+				return nil, nil, fmt.Errorf("row %d has %d values but there are %d columns", i, len(row), len(columns))
+			}
+			rows[i] = row
+		case map[string]any:
+			for column := range row {
+				if !columnSet[column] {
+					return nil, nil, fmt.Errorf("row %d has the column '%s' which is not part of the insert columns", i, column)
+				}
+			}
+
+			values := make([]any, len(columns))
+			for j, column := range columns {
+				values[j] = row[column]
+			}
+			rows[i] = values
+		default:
+			return nil, nil, fmt.Errorf("row %d should be an array of values or an object", i)
+		}
+	}
+
+	return columns, rows, nil
+}
+
+type insertStatement struct {
+	Query string
+	Key   *tableKey
+	Types map[string]models.ColumnModel // columns of a logical type, checked for every inserted row
+}
+
+// buildInsertStatement validates the insert request and builds the INSERT statement shared by single and bulk inserts,
+// the key columns depend on the key strategy of the table. The table columns are resolved once for every check
+func buildInsertStatement(insertModel models.InsertModel) (*insertStatement, error) {
+	if err := ValidateTableName(insertModel.TableName); err != nil {
+		return nil, err
+	}
+
+	columnsPtr, err := GetTableColumns(insertModel.TableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table columns: %w", err)
+	}
+	tableColumns := *columnsPtr
+
+	if err := validateColumnsOf(insertModel.TableName, tableColumns, insertModel.Columns); err != nil {
+		return nil, err
+	}
+
+	key, err := tableKeyOf(insertModel.TableName, tableColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	types := writeCheckedColumnsOf(tableColumns)
+
+	if err := validateWritableColumns(types, insertModel.Columns); err != nil {
+		return nil, err
+	}
+
+	insertedSet := make(map[string]bool)
+	for _, column := range insertModel.Columns {
+		insertedSet[strings.TrimSpace(column)] = true
+	}
+
+	for _, column := range key.Columns {
+		if key.clientSupplied() && !insertedSet[column] {
+			return nil, fmt.Errorf("insert request should contain the primary key column '%s', the table uses the %s key strategy", column, key.Strategy)
+		}
+		if !key.clientSupplied() && insertedSet[column] {
+			return nil, fmt.Errorf("insert request should not contains the %s, it is generated by the %s key strategy and will be returned in the response", column, key.Strategy)
+		}
+	}
+
+	conflictClause, err := buildOnConflictClause(insertModel, tableColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the SQL with placeholders
+	columns := insertModel.Columns
+	if key.generated() {
+		columns = append([]string{key.Columns[0]}, columns...)
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+
+	values := fmt.Sprintf("(%s) VALUES (%s)", strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if len(columns) == 0 {
+		values = "DEFAULT VALUES"
+	}
+
+	// RETURNING gives back the key of the row that was written, on an upsert that
+	// updated an existing row this is the key of that row
+	query := fmt.Sprintf("INSERT INTO %s %s%s RETURNING %s",
+		insertModel.TableName,
+		values,
+		conflictClause,
+		strings.Join(key.Columns, ", "))
+
+	return &insertStatement{Query: query, Key: key, Types: types}, nil
+}
+
+// insertRow runs the prepared insert statement for one row of values, with a freshly generated key when the strategy generates one
+func insertRow(db dbclass.Querier, stmt *sql.Stmt, insertModel models.InsertModel, statement *insertStatement, values []any) (*models.InsertResult, error) {
+	key := statement.Key
+
+	// normalize a copy, the values belong to the request
+	values = append([]any(nil), values...)
+	if err := normalizeLogicalValues(statement.Types, insertModel.Columns, values); err != nil {
+		return nil, err
+	}
+
+	args := make([]any, 0, len(values)+1)
+
+	var generatedKey string
+	if key.generated() {
+		var err error
+		generatedKey, err = generateKey(key.Strategy)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, generatedKey)
+	}
+
+	if key.clientSupplied() {
+		for i, column := range insertModel.Columns {
+			if values[i] == nil && containsColumn(key.Columns, column) {
+				return nil, fmt.Errorf("primary key column '%s' cannot be null", column)
+			}
+		}
+	}
+	args = append(args, values...)
+
+	// an upsert reports whether it inserted or updated by looking for the conflicting row first,
+	// the caller runs both in one transaction
+	var existingKey any
+	if insertModel.OnConflict != nil {
+		var err error
+		existingKey, err = findConflictingKey(db, insertModel, key, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	returned := make([]any, len(key.Columns))
+	dest := make([]any, len(key.Columns))
+	for i := range returned {
+		dest[i] = &returned[i]
+	}
+
+	err := stmt.QueryRow(args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		// DO NOTHING skipped the row, report the key of the row we conflicted with
+		return &models.InsertResult{ID: existingKey, Status: InsertStatusSkipped}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	id := key.value(returned)
+	if existingKey != nil || (generatedKey != "" && id != generatedKey) {
+		return &models.InsertResult{ID: id, Status: InsertStatusUpdated}, nil
+	}
+
+	return &models.InsertResult{ID: id, Status: InsertStatusInserted}, nil
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// findConflictingKey looks up the key of the existing row matching the conflict columns of an insert
+func findConflictingKey(db dbclass.Querier, insertModel models.InsertModel, key *tableKey, values []any) (any, error) {
+	valueByColumn := make(map[string]any)
+	for i, column := range insertModel.Columns {
+		valueByColumn[column] = values[i]
+	}
+
+	var conditions []string
+	var params []any
+	for _, column := range insertModel.OnConflict.Columns {
+		value, ok := valueByColumn[column]
+		if !ok {
+			// the conflict column was not inserted so there is no way to match the existing row
+			return nil, nil
+		}
+		conditions = append(conditions, fmt.Sprintf("%s IS ?", column))
+		params = append(params, value)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT 1", strings.Join(key.Columns, ", "), insertModel.TableName, strings.Join(conditions, " AND "))
+
+	existing := make([]any, len(key.Columns))
+	dest := make([]any, len(key.Columns))
+	for i := range existing {
+		dest[i] = &existing[i]
+	}
+
+	err := db.QueryRow(query, params...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the conflicting row: %w", err)
+	}
+
+	return key.value(existing), nil
+}
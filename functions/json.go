@@ -0,0 +1,129 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+// jsonArrow separates a column from a JSON path in selected columns and filters, like meta->address.city
+const jsonArrow = "->"
+
+const (
+	JSONPatchSet    = "set"
+	JSONPatchRemove = "remove"
+	JSONPatchAppend = "append"
+)
+
+// isJSONSelection reports whether a result column comes from SQLite's -> operator, which always returns JSON text,
+// the ->> operator returns plain SQL values
+func isJSONSelection(name string) bool {
+	return strings.Contains(name, jsonArrow) && !strings.Contains(name, "->>")
+}
+
+// splitJSONSelector splits "column->path" into the column and its JSON path, found is false for a plain column.
+// The path may leave out the leading $ so meta->address.city and meta->$.address.city are the same, numeric
+// segments are array positions like in PostgREST so tags->0 and items.1.name are tags->[0] and items[1].name
+func splitJSONSelector(selector string) (string, string, bool, error) {
+	column, path, found := strings.Cut(strings.TrimSpace(selector), jsonArrow)
+	if !found {
+		return selector, "", false, nil
+	}
+
+	column = strings.TrimSpace(column)
+	path = strings.TrimSpace(path)
+	switch {
+	case strings.HasPrefix(path, "$"):
+	case strings.HasPrefix(path, "["):
+		path = "$" + path
+	default:
+		segments := strings.Split(path, ".")
+		path = "$"
+		for _, segment := range segments {
+			if segment != "" && strings.Trim(segment, "0123456789") == "" {
+				path += "[" + segment + "]"
+			} else {
+				path += "." + segment
+			}
+		}
+	}
+
+	if err := ValidateJSONPath(path); err != nil {
+		return "", "", true, fmt.Errorf("invalid json selector %s: %v", selector, err)
+	}
+
+	return column, path, true, nil
+}
+
+// selectExpressions turns the selected columns into the SELECT list and returns the table columns they read,
+// a json selector is selected with the -> operator under its own text so the row encoder returns it as nested JSON
+func selectExpressions(selected []string) ([]string, []string, error) {
+	expressions := make([]string, len(selected))
+	columns := make([]string, len(selected))
+	for i, selector := range selected {
+		column, path, found, err := splitJSONSelector(selector)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		columns[i] = column
+		expressions[i] = selector
+		if found {
+			// the path only holds the characters jsonPathPattern allows, so it can be written as a literal
+			expressions[i] = fmt.Sprintf("%s -> '%s' AS \"%s\"", column, path, strings.TrimSpace(selector))
+		}
+	}
+
+	return expressions, columns, nil
+}
+
+// buildJSONPatch returns the expression that applies the patch operations of an update to a json column in order,
+// a NULL column is patched as an empty array when the first operation works on the top level array and as an
+// empty object otherwise. Appending to an array that does not exist yet creates it
+func buildJSONPatch(column string, operations []models.JSONPatchModel) (string, []any, error) {
+	if len(operations) == 0 {
+		return "", nil, fmt.Errorf("json patch for column %s needs at least one operation", column)
+	}
+
+	empty := "{}"
+	first := operations[0]
+	if strings.HasPrefix(first.Path, "$[") || (first.Path == "$" && strings.ToLower(strings.TrimSpace(first.Op)) == JSONPatchAppend) {
+		empty = "[]"
+	}
+
+	expression := fmt.Sprintf("COALESCE(%s, '%s')", column, empty)
+	var params []any
+	for _, operation := range operations {
+		if err := ValidateJSONPath(operation.Path); err != nil {
+			return "", nil, fmt.Errorf("json patch for column %s: %v", column, err)
+		}
+
+		op := strings.ToLower(strings.TrimSpace(operation.Op))
+		var value []byte
+		if op != JSONPatchRemove {
+			var err error
+			if value, err = json.Marshal(operation.Value); err != nil {
+				return "", nil, fmt.Errorf("json patch for column %s: %v", column, err)
+			}
+		}
+
+		switch op {
+		case JSONPatchSet:
+			expression = fmt.Sprintf("json_set(%s, ?, json(?))", expression)
+			params = append(params, operation.Path, string(value))
+		case JSONPatchRemove:
+			expression = fmt.Sprintf("json_remove(%s, ?)", expression)
+			params = append(params, operation.Path)
+		case JSONPatchAppend:
+			// json_insert leaves an existing array alone and [#] is the position after its last element
+			expression = fmt.Sprintf("json_insert(json_insert(%s, ?, json('[]')), ?, json(?))", expression)
+			params = append(params, operation.Path, operation.Path+"[#]", string(value))
+		default:
+			return "", nil, fmt.Errorf("invalid json patch operation: %s, use set, remove or append", operation.Op)
+		}
+	}
+
+	return expression, params, nil
+}
@@ -0,0 +1,102 @@
+package functions
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func createDocsTable(t *testing.T) {
+	t.Helper()
+	err := CreateTable(models.TableModel{
+		Name:        "docs",
+		KeyStrategy: "client",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "body", DataType: "json", Nullable: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+}
+
+func TestJSONColumnKeepsScalarsAsSent(t *testing.T) {
+	openTestDB(t)
+	createDocsTable(t)
+
+	tests := []struct {
+		name string
+		sent any
+		want string
+	}{
+		{"big integer text", "12345678901234567890", "12345678901234567890"},
+		{"decimal text", "1.50", "1.50"},
+		{"object", map[string]any{"a": 1}, `{"a":1}`},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := InsertIntoTable(models.InsertModel{TableName: "docs", Columns: []string{"id", "body"}, Values: []any{i + 1, tt.sent}}); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+
+			var storageClass, stored string
+			if err := dbclass.DB.QueryRow("SELECT typeof(body), body FROM docs WHERE id = ?", i+1).Scan(&storageClass, &stored); err != nil {
+				t.Fatalf("read back: %v", err)
+			}
+			if storageClass != "text" || stored != tt.want {
+				t.Fatalf("stored %s %q, want text %q", storageClass, stored, tt.want)
+			}
+
+			row, err := GetRowByKey("docs", strconv.Itoa(i+1), nil)
+			if err != nil {
+				t.Fatalf("get row: %v", err)
+			}
+			raw, ok := row["body"].(json.RawMessage)
+			if !ok || string(raw) != tt.want {
+				t.Fatalf("encoded body %#v, want nested JSON %s", row["body"], tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchOnNullColumn(t *testing.T) {
+	openTestDB(t)
+	createDocsTable(t)
+	mustExec(t, "INSERT INTO docs (id, body) VALUES (1, NULL), (2, NULL)")
+
+	tests := []struct {
+		name  string
+		id    int
+		patch []models.JSONPatchModel
+		want  string
+	}{
+		{"append at the top level", 1, []models.JSONPatchModel{{Op: "append", Path: "$", Value: "a"}}, `["a"]`},
+		{"set a key", 2, []models.JSONPatchModel{{Op: "set", Path: "$.name", Value: "x"}}, `{"name":"x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			affected, err := UpdateTable(models.UpdateModel{
+				TableName: "docs",
+				JSON:      map[string][]models.JSONPatchModel{"body": tt.patch},
+				Where:     &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "id", Operator: "eq", Value: tt.id}},
+			})
+			if err != nil || affected != 1 {
+				t.Fatalf("update: affected %d, %v", affected, err)
+			}
+
+			var stored string
+			if err := dbclass.DB.QueryRow("SELECT body FROM docs WHERE id = ?", tt.id).Scan(&stored); err != nil {
+				t.Fatalf("read back: %v", err)
+			}
+			if stored != tt.want {
+				t.Fatalf("stored %s, want %s", stored, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package functions
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+	"github.com/google/uuid"
+)
+
+const (
+	KeyStrategyUUID          = "uuid"
+	KeyStrategyUUIDv7        = "uuid_v7"
+	KeyStrategyULID          = "ulid"
+	KeyStrategyAutoIncrement = "autoincrement"
+	KeyStrategyClient        = "client"
+	KeyStrategyComposite     = "composite"
+)
+
+// rowidColumn is the key of tables created without a primary key
+const rowidColumn = "rowid"
+
+// tableKey is the primary key of a table and how new values for it are produced
+type tableKey struct {
+	Strategy string
+	Columns  []string
+}
+
+// generated reports whether the server creates the key value before inserting
+func (k *tableKey) generated() bool {
+	return k.Strategy == KeyStrategyUUID || k.Strategy == KeyStrategyUUIDv7 || k.Strategy == KeyStrategyULID
+}
+
+// clientSupplied reports whether every key column has to be part of the insert
+func (k *tableKey) clientSupplied() bool {
+	return k.Strategy == KeyStrategyClient || k.Strategy == KeyStrategyComposite
+}
+
+// value turns the key columns returned by an insert into the id reported to the client,
+// a single value for one column keys and a column -> value object for composite keys
+func (k *tableKey) value(values []any) any {
+	for i, value := range values {
+		if b, ok := value.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+
+	if len(k.Columns) == 1 {
+		return values[0]
+	}
+
+	key := make(map[string]any, len(k.Columns))
+	for i, column := range k.Columns {
+		key[column] = values[i]
+	}
+	return key
+}
+
+// generateKey creates a new key value for the generated strategies
+func generateKey(strategy string) (string, error) {
+	switch strategy {
+	case KeyStrategyUUID:
+		return uuid.New().String(), nil
+	case KeyStrategyUUIDv7:
+		id, err := uuid.NewV7()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate uuid v7: %v", err)
+		}
+		return id.String(), nil
+	case KeyStrategyULID:
+		return newULID()
+	}
+
+	return "", fmt.Errorf("key strategy %s does not generate keys", strategy)
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a 26 character ULID, 48 bits of millisecond timestamp followed by 80 random bits
+// in Crockford base32, so keys sort by creation time
+func newULID() (string, error) {
+	var data [16]byte
+
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		data[i] = byte(ms >> (40 - 8*i))
+	}
+
+	if _, err := rand.Read(data[6:]); err != nil {
+		return "", fmt.Errorf("failed to generate ulid: %v", err)
+	}
+
+	// 128 bits are written as 26 characters of 5 bits, the first character only holds 3 bits
+	out := make([]byte, 26)
+	for i := range out {
+		low := (25 - i) * 5
+		var chunk byte
+		for j := 0; j < 5; j++ {
+			position := low + j
+			if position > 127 {
+				break
+			}
+			if data[15-position/8]&(1<<(position%8)) != 0 {
+				chunk |= 1 << j
+			}
+		}
+		out[i] = crockfordAlphabet[chunk]
+	}
+
+	return string(out), nil
+}
+
+// isTextType reports whether a declared SQLite type stores text
+func isTextType(declType string) bool {
+	declType = strings.ToUpper(declType)
+	return strings.Contains(declType, "TEXT") || strings.Contains(declType, "CHAR") || strings.Contains(declType, "CLOB")
+}
+
+// inferKeyStrategy picks the strategy of a table that has none recorded from its primary key columns,
+// a text id keeps the original uuid v4 behaviour
+func inferKeyStrategy(primaryKeys []models.ColumnModel) string {
+	switch {
+	case len(primaryKeys) == 0:
+		return KeyStrategyAutoIncrement
+	case len(primaryKeys) > 1:
+		return KeyStrategyComposite
+	case strings.EqualFold(primaryKeys[0].DataType, "INTEGER"):
+		return KeyStrategyAutoIncrement
+	case primaryKeys[0].LogicalType == LogicalTypeUUID:
+		return KeyStrategyUUID
+	case primaryKeys[0].Name == "id" && isTextType(primaryKeys[0].DataType):
+		return KeyStrategyUUID
+	}
+
+	return KeyStrategyClient
+}
+
+// ValidateKeyStrategy checks that the primary key columns fit the strategy, columns carry SQLite types
+func ValidateKeyStrategy(strategy string, primaryKeys []models.ColumnModel) error {
+	switch strategy {
+	case KeyStrategyUUID, KeyStrategyUUIDv7, KeyStrategyULID:
+		if len(primaryKeys) != 1 || !isTextType(primaryKeys[0].DataType) {
+			return fmt.Errorf("key strategy %s requires exactly one text primary key column", strategy)
+		}
+		if strategy == KeyStrategyULID && primaryKeys[0].LogicalType == LogicalTypeUUID {
+			return fmt.Errorf("key strategy %s generates 26 character keys which do not fit a uuid column", strategy)
+		}
+	case KeyStrategyAutoIncrement:
+		if len(primaryKeys) != 1 || !strings.EqualFold(primaryKeys[0].DataType, "INTEGER") {
+			return fmt.Errorf("key strategy %s requires exactly one int primary key column", strategy)
+		}
+	case KeyStrategyClient:
+		if len(primaryKeys) == 0 {
+			return fmt.Errorf("key strategy %s requires at least one primary key column", strategy)
+		}
+	case KeyStrategyComposite:
+		if len(primaryKeys) < 2 {
+			return fmt.Errorf("key strategy %s requires at least two primary key columns", strategy)
+		}
+	default:
+		return fmt.Errorf("invalid key strategy: %s", strategy)
+	}
+
+	return nil
+}
+
+// resolveTableKey returns the primary key of a table with the strategy recorded in the admin metadata,
+// or the inferred one for tables created outside of CreateTable
+func resolveTableKey(tableName string) (*tableKey, error) {
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return tableKeyOf(tableName, *columnsPtr)
+}
+
+// tableKeyOf is resolveTableKey for table columns the caller already resolved
+func tableKeyOf(tableName string, columns []models.ColumnModel) (*tableKey, error) {
+	var primaryKeys []models.ColumnModel
+	for _, column := range columns {
+		if column.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, column)
+		}
+	}
+
+	strategy, err := dbclass.GetTableKeyStrategy(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the key strategy: %w", err)
+	}
+
+	// a recorded strategy that no longer fits the schema, e.g. after a raw SQL change, is ignored
+	if strategy == "" || ValidateKeyStrategy(strategy, primaryKeys) != nil {
+		strategy = inferKeyStrategy(primaryKeys)
+	}
+
+	key := &tableKey{Strategy: strategy}
+	for _, column := range primaryKeys {
+		key.Columns = append(key.Columns, column.Name)
+	}
+	if len(key.Columns) == 0 {
+		key.Columns = []string{rowidColumn}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,186 @@
+package functions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MultiX0/db-test/models"
+	"github.com/google/uuid"
+)
+
+// ulidTime decodes the millisecond timestamp held by the first 10 characters of a ULID
+func ulidTime(t *testing.T, id string) time.Time {
+	t.Helper()
+	var ms int64
+	for _, char := range id[:10] {
+		index := strings.IndexRune(crockfordAlphabet, char)
+		if index < 0 {
+			t.Fatalf("ulid %s has a character outside of the alphabet", id)
+		}
+		ms = ms<<5 | int64(index)
+	}
+	return time.UnixMilli(ms)
+}
+
+func TestNewULID(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	first, err := newULID()
+	if err != nil {
+		t.Fatalf("ulid: %v", err)
+	}
+
+	if len(first) != 26 {
+		t.Fatalf("got ulid %s of length %d, want 26", first, len(first))
+	}
+	for _, char := range first {
+		if !strings.ContainsRune(crockfordAlphabet, char) {
+			t.Fatalf("ulid %s has %q which is not in the Crockford alphabet", first, char)
+		}
+	}
+	// 128 bits leave 3 bits for the first character
+	if first[0] > '7' {
+		t.Fatalf("ulid %s overflows 128 bits", first)
+	}
+
+	if created := ulidTime(t, first); created.Before(before) || created.After(time.Now()) {
+		t.Fatalf("ulid %s holds %v, want the time it was created", first, created)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second, err := newULID()
+	if err != nil {
+		t.Fatalf("ulid: %v", err)
+	}
+	if second <= first {
+		t.Fatalf("ulid %s created later sorts before %s", second, first)
+	}
+}
+
+func TestGenerateUUIDv7(t *testing.T) {
+	first, err := generateKey(KeyStrategyUUIDv7)
+	if err != nil {
+		t.Fatalf("uuid v7: %v", err)
+	}
+
+	parsed, err := uuid.Parse(first)
+	if err != nil || parsed.Version() != 7 || parsed.String() != first {
+		t.Fatalf("got %s (%v), want a lowercase version 7 uuid", first, err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second, _ := generateKey(KeyStrategyUUIDv7)
+	if second <= first {
+		t.Fatalf("uuid v7 %s created later sorts before %s", second, first)
+	}
+
+	if _, err := generateKey(KeyStrategyClient); err == nil {
+		t.Fatalf("client keys should not be generated")
+	}
+}
+
+func TestInsertReturnsKey(t *testing.T) {
+	openTestDB(t)
+
+	tests := []struct {
+		name   string
+		table  models.TableModel
+		insert models.InsertModel
+		want   any
+	}{
+		{
+			name: "autoincrement",
+			table: models.TableModel{
+				Name:        "counters",
+				KeyStrategy: KeyStrategyAutoIncrement,
+				Columns: []models.ColumnModel{
+					{Name: "id", DataType: "int", IsPrimaryKey: true},
+					{Name: "label", DataType: "txt"},
+				},
+			},
+			insert: models.InsertModel{Columns: []string{"label"}, Values: []any{"a"}},
+			want:   int64(1),
+		},
+		{
+			name: "client",
+			table: models.TableModel{
+				Name:        "countries",
+				KeyStrategy: KeyStrategyClient,
+				Columns: []models.ColumnModel{
+					{Name: "code", DataType: "txt", IsPrimaryKey: true},
+					{Name: "name", DataType: "txt"},
+				},
+			},
+			insert: models.InsertModel{Columns: []string{"code", "name"}, Values: []any{"NL", "Netherlands"}},
+			want:   "NL",
+		},
+		{
+			name: "composite",
+			table: models.TableModel{
+				Name:        "memberships",
+				KeyStrategy: KeyStrategyComposite,
+				Columns: []models.ColumnModel{
+					{Name: "team", DataType: "txt", IsPrimaryKey: true},
+					{Name: "member", DataType: "int", IsPrimaryKey: true},
+				},
+			},
+			insert: models.InsertModel{Columns: []string{"team", "member"}, Values: []any{"reds", 7}},
+			want:   map[string]any{"team": "reds", "member": int64(7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CreateTable(tt.table); err != nil {
+				t.Fatalf("create table: %v", err)
+			}
+
+			tt.insert.TableName = tt.table.Name
+			result, err := InsertIntoTable(tt.insert)
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			if !reflect.DeepEqual(result.ID, tt.want) {
+				t.Fatalf("got id %#v, want %#v", result.ID, tt.want)
+			}
+		})
+	}
+
+	if _, err := InsertIntoTable(models.InsertModel{TableName: "memberships", Columns: []string{"team"}, Values: []any{"blues"}}); err == nil {
+		t.Fatalf("a composite key insert without every key column should fail")
+	}
+}
+
+func TestInferKeyStrategy(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE numbered (id INTEGER PRIMARY KEY, name TEXT)")
+	mustExec(t, "CREATE TABLE texts (id TEXT PRIMARY KEY, name TEXT)")
+	mustExec(t, "CREATE TABLE codes (code VARCHAR(8) PRIMARY KEY)")
+	mustExec(t, "CREATE TABLE pairs (a INTEGER, b TEXT, PRIMARY KEY (a, b))")
+	mustExec(t, "CREATE TABLE loose (name TEXT)")
+
+	tests := []struct {
+		table    string
+		strategy string
+		columns  []string
+	}{
+		{"numbered", KeyStrategyAutoIncrement, []string{"id"}},
+		{"texts", KeyStrategyUUID, []string{"id"}},
+		{"codes", KeyStrategyClient, []string{"code"}},
+		{"pairs", KeyStrategyComposite, []string{"a", "b"}},
+		{"loose", KeyStrategyAutoIncrement, []string{rowidColumn}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			key, err := resolveTableKey(tt.table)
+			if err != nil {
+				t.Fatalf("resolve key: %v", err)
+			}
+			if key.Strategy != tt.strategy || !reflect.DeepEqual(key.Columns, tt.columns) {
+				t.Fatalf("got %s on %v, want %s on %v", key.Strategy, key.Columns, tt.strategy, tt.columns)
+			}
+		})
+	}
+}
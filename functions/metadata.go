@@ -0,0 +1,201 @@
+package functions
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// getTableSQL returns the CREATE TABLE statement of a table
+func getTableSQL(tableName string) (string, error) {
+	var tableSQL sql.NullString
+	err := dbclass.DB.QueryRow("SELECT sql FROM sqlite_schema WHERE name = ? AND type = 'table'", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return tableSQL.String, nil
+}
+
+// recordTableMetadata stores the current schema of a table in admin.db, the description, key strategy
+// and column descriptions of the given model are recorded with it
+func recordTableMetadata(table models.TableModel) error {
+	tableSQL, err := getTableSQL(table.Name)
+	if err != nil {
+		return err
+	}
+
+	columnsPtr, err := GetTableColumns(table.Name)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]models.ColumnModel)
+	for _, column := range table.Columns {
+		given[column.Name] = column
+	}
+
+	columns := *columnsPtr
+	for i := range columns {
+		column, ok := given[columns[i].Name]
+		if !ok {
+			continue
+		}
+
+		columns[i].Description = column.Description
+
+		// a column given with its type decides the logical type, one given with only a description keeps the detected type
+		if column.DataType != "" {
+			columns[i].LogicalType = column.LogicalType
+			if columns[i].LogicalType == "" {
+				columns[i].LogicalType = logicalTypeOf(column.DataType)
+			}
+		}
+	}
+
+	if table.KeyStrategy == "" {
+		key, err := resolveTableKey(table.Name)
+		if err != nil {
+			return err
+		}
+		table.KeyStrategy = key.Strategy
+	}
+
+	table.Sql = tableSQL
+	if err := dbclass.InsertTable(table); err != nil {
+		return fmt.Errorf("failed to record table %s: %v", table.Name, err)
+	}
+
+	if err := dbclass.InsertColumns(table.Name, columns); err != nil {
+		return fmt.Errorf("failed to record the columns of %s: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+// recordAlteredTable records the schema of an altered table with the descriptions and logical types it already had,
+// renamed maps old column names to new ones and changed is the definition of an added or altered column
+func recordAlteredTable(tableName string, renamed map[string]string, changed *models.ColumnModel) error {
+	metadata, err := dbclass.GetTableMetadata(tableName)
+	if err != nil {
+		return err
+	}
+
+	table := recordedTable(tableName, metadata, renamed)
+	if changed != nil {
+		replaced := false
+		for i, column := range table.Columns {
+			if column.Name != changed.Name {
+				continue
+			}
+
+			definition := *changed
+			if definition.Description == "" {
+				definition.Description = column.Description
+			}
+			table.Columns[i] = definition
+			replaced = true
+		}
+
+		if !replaced {
+			table.Columns = append(table.Columns, *changed)
+		}
+	}
+
+	return recordTableMetadata(table)
+}
+
+// recordedTable turns the metadata of a table into the model recordTableMetadata records, under tableName
+// and with the columns in renamed under their new names
+func recordedTable(tableName string, metadata *dbclass.TableMetadata, renamed map[string]string) models.TableModel {
+	table := models.TableModel{Name: tableName}
+	if metadata == nil {
+		return table
+	}
+
+	table.Description = metadata.Description
+	for name, column := range metadata.Columns {
+		if newName, ok := renamed[name]; ok {
+			name = newName
+		}
+		table.Columns = append(table.Columns, models.ColumnModel{Name: name, Description: column.Description, DataType: column.DataType})
+	}
+
+	return table
+}
+
+// ReconcileMetadata brings admin.db in line with the main database, tables created or altered through raw SQL
+// are recorded again with the descriptions that still apply and tables that no longer exist are forgotten
+func ReconcileMetadata() error {
+	rows, err := dbclass.DB.Query("SELECT name, sql FROM sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var tableSQL sql.NullString
+		if err := rows.Scan(&name, &tableSQL); err != nil {
+			rows.Close()
+			return err
+		}
+		current[name] = tableSQL.String
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for name, tableSQL := range current {
+		metadata, err := dbclass.GetTableMetadata(name)
+		if err != nil {
+			return err
+		}
+
+		if metadata != nil && metadata.SchemaSQL == tableSQL {
+			continue
+		}
+
+		table := models.TableModel{Name: name}
+		if metadata == nil {
+			log.Printf("recording table %s created outside of the API", name)
+		} else {
+			log.Printf("recording changes to table %s made outside of the API", name)
+
+			// the key strategy is kept when it still fits, resolveTableKey checks it against the new schema
+			table.Description = metadata.Description
+			for columnName, column := range metadata.Columns {
+				table.Columns = append(table.Columns, models.ColumnModel{Name: columnName, Description: column.Description})
+			}
+		}
+
+		if err := recordTableMetadata(table); err != nil {
+			return err
+		}
+	}
+
+	recorded, err := dbclass.GetRecordedTables()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range recorded {
+		if _, ok := current[name]; ok {
+			continue
+		}
+
+		log.Printf("forgetting table %s which no longer exists", name)
+		if err := dbclass.DeleteTableMetadata(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package functions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func mustMetadata(t *testing.T, tableName string) *dbclass.TableMetadata {
+	t.Helper()
+	metadata, err := dbclass.GetTableMetadata(tableName)
+	if err != nil {
+		t.Fatalf("get metadata of %s: %v", tableName, err)
+	}
+	if metadata == nil {
+		t.Fatalf("table %s is not recorded", tableName)
+	}
+	return metadata
+}
+
+func TestRawSQLRecordsSchemaChanges(t *testing.T) {
+	openTestDB(t)
+
+	if _, err := RawSQL("CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	metadata := mustMetadata(t, "notes")
+	if !strings.Contains(metadata.SchemaSQL, "body TEXT") || metadata.KeyStrategy != KeyStrategyAutoIncrement {
+		t.Fatalf("got schema %q with strategy %q, want the created table keyed by autoincrement", metadata.SchemaSQL, metadata.KeyStrategy)
+	}
+	if len(metadata.Columns) != 2 || metadata.Columns["body"].StorageType != "TEXT" {
+		t.Fatalf("got columns %v, want id and body", metadata.Columns)
+	}
+
+	if _, err := RawSQL("ALTER TABLE notes ADD COLUMN pinned BOOLEAN"); err != nil {
+		t.Fatalf("alter: %v", err)
+	}
+	metadata = mustMetadata(t, "notes")
+	if _, ok := metadata.Columns["pinned"]; !ok || !strings.Contains(metadata.SchemaSQL, "pinned") {
+		t.Fatalf("got columns %v and schema %q, want the added column recorded", metadata.Columns, metadata.SchemaSQL)
+	}
+
+	if _, err := RawSQL("DROP TABLE notes"); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if metadata, err := dbclass.GetTableMetadata("notes"); err != nil || metadata != nil {
+		t.Fatalf("got metadata %v (%v), want the dropped table forgotten", metadata, err)
+	}
+
+	var columns int
+	if err := dbclass.AdminDB.QueryRow("SELECT COUNT(*) FROM columns WHERE table_name = 'notes'").Scan(&columns); err != nil {
+		t.Fatalf("count columns: %v", err)
+	}
+	if columns != 0 {
+		t.Fatalf("got %d recorded columns of the dropped table, want 0", columns)
+	}
+}
+
+func TestReconcileMetadataKeepsDescriptionsAndLogicalTypes(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "tickets",
+		Description: "support tickets",
+		KeyStrategy: KeyStrategyUUIDv7,
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "uuid", IsPrimaryKey: true, Description: "ticket id"},
+			{Name: "state", DataType: "enum", EnumValues: []string{"open", "closed"}, Description: "where the ticket is"},
+			{Name: "meta", DataType: "json", Nullable: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	// a change made while the server was not looking, reconciled like on startup
+	mustExec(t, "ALTER TABLE tickets ADD COLUMN owner TEXT")
+	mustExec(t, "CREATE TABLE stray (name TEXT)")
+	if _, err := dbclass.AdminDB.Exec("INSERT INTO tables (name) VALUES ('gone')"); err != nil {
+		t.Fatalf("record a missing table: %v", err)
+	}
+	if err := ReconcileMetadata(); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	metadata := mustMetadata(t, "tickets")
+	if metadata.Description != "support tickets" || metadata.KeyStrategy != KeyStrategyUUIDv7 {
+		t.Fatalf("got description %q and strategy %q, want them kept", metadata.Description, metadata.KeyStrategy)
+	}
+
+	want := map[string]dbclass.ColumnMetadata{
+		"id":    {Description: "ticket id", DataType: "uuid", StorageType: "TEXT"},
+		"state": {Description: "where the ticket is", DataType: "enum", StorageType: "TEXT", EnumValues: []string{"open", "closed"}},
+		"meta":  {DataType: "json", StorageType: "TEXT"},
+		"owner": {DataType: "TEXT", StorageType: "TEXT"},
+	}
+	if !reflect.DeepEqual(metadata.Columns, want) {
+		t.Fatalf("got columns %+v\nwant %+v", metadata.Columns, want)
+	}
+
+	mustMetadata(t, "stray")
+	if metadata, err := dbclass.GetTableMetadata("gone"); err != nil || metadata != nil {
+		t.Fatalf("got metadata %v (%v), want the missing table forgotten", metadata, err)
+	}
+}
@@ -0,0 +1,263 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+	"github.com/MultiX0/db-test/utils"
+)
+
+const defaultMaxSelectDocuments = 1000
+
+// cursorColumnPrefix is the alias of the extra order key columns selected to build the next cursor
+const cursorColumnPrefix = "__cursor_"
+
+type orderKey struct {
+	Column     string
+	Descending bool
+}
+
+type cursorToken struct {
+	Order  string `json:"o"`
+	Values []any  `json:"v"`
+}
+
+// MaxSelectDocuments is the biggest page a select can return, read from MAX_SELECT_DOCUMENTS
+func MaxSelectDocuments() int {
+	max := utils.GetEnvInt("MAX_SELECT_DOCUMENTS", defaultMaxSelectDocuments)
+	if max <= 0 {
+		return defaultMaxSelectDocuments
+	}
+	return max
+}
+
+// resolvePageSize validates the requested limit and offset, capped pages never go above MaxSelectDocuments
+// while uncapped ones (streamed exports) return -1 when no limit is given, which SQLite reads as no limit
+func resolvePageSize(selectModel models.SelectModel, capped bool) (int, int, error) {
+	max := MaxSelectDocuments()
+
+	limit := max
+	if !capped {
+		limit = -1
+	}
+
+	if selectModel.Limit != nil {
+		if *selectModel.Limit <= 0 {
+			return 0, 0, fmt.Errorf("limit should be greater than 0")
+		}
+		if !capped || *selectModel.Limit < max {
+			limit = *selectModel.Limit
+		}
+	}
+
+	offset := 0
+	if selectModel.Offset != nil {
+		if *selectModel.Offset < 0 {
+			return 0, 0, fmt.Errorf("offset cannot be negative")
+		}
+		if selectModel.Cursor != "" {
+			return 0, 0, fmt.Errorf("offset cannot be used together with cursor")
+		}
+		offset = *selectModel.Offset
+	}
+
+	return limit, offset, nil
+}
+
+// resolveOrderKeys validates the requested order and appends a unique tiebreaker (rowid or the primary key)
+// so every row has a stable position, which is what keyset pagination relies on
+func resolveOrderKeys(tableName string, order []models.OrderModel) ([]orderKey, error) {
+	var keys []orderKey
+	seen := make(map[string]bool)
+
+	for _, item := range order {
+		column := strings.TrimSpace(item.Column)
+		if column == "*" {
+			return nil, fmt.Errorf("cannot order by '*'")
+		}
+
+		if err := ValidateColumns(tableName, []string{column}); err != nil {
+			return nil, err
+		}
+
+		var descending bool
+		switch strings.ToLower(strings.TrimSpace(item.Direction)) {
+		case "", "asc":
+			descending = false
+		case "desc":
+			descending = true
+		default:
+			return nil, fmt.Errorf("invalid order direction: %s", item.Direction)
+		}
+
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		keys = append(keys, orderKey{Column: column, Descending: descending})
+	}
+
+	tiebreakers, err := uniqueRowKey(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, column := range tiebreakers {
+		if !seen[column] {
+			keys = append(keys, orderKey{Column: column})
+		}
+	}
+
+	return keys, nil
+}
+
+// uniqueRowKey returns the columns that identify a row, rowid for normal tables and the primary key for WITHOUT ROWID tables
+func uniqueRowKey(tableName string) ([]string, error) {
+	rows, err := dbclass.DB.Query(fmt.Sprintf("SELECT rowid FROM %s LIMIT 0", tableName))
+	if err == nil {
+		rows.Close()
+		return []string{"rowid"}, nil
+	}
+
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var primaryKeys []string
+	for _, column := range *columnsPtr {
+		if column.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, column.Name)
+		}
+	}
+
+	return primaryKeys, nil
+}
+
+func orderSignature(keys []orderKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "asc"
+		if key.Descending {
+			direction = "desc"
+		}
+		parts[i] = key.Column + ":" + direction
+	}
+	return strings.Join(parts, ",")
+}
+
+func buildOrderByClause(keys []orderKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.Descending {
+			direction = "DESC"
+		}
+		parts[i] = key.Column + " " + direction
+	}
+	return strings.Join(parts, ", ")
+}
+
+// EncodeCursor builds the opaque cursor pointing right after a row with the given raw order key values,
+// blobs are written as a BlobValue so they are compared as blobs again and not as their base64 text
+func EncodeCursor(keys []orderKey, values []any) (string, error) {
+	tokenValues := make([]any, len(values))
+	for i, value := range values {
+		if data, ok := value.([]byte); ok {
+			tokenValues[i] = BlobValue{Type: "blob", Encoding: BlobEncodingBase64, Data: base64.StdEncoding.EncodeToString(data)}
+			continue
+		}
+		tokenValues[i] = value
+	}
+
+	data, err := json.Marshal(cursorToken{Order: orderSignature(keys), Values: tokenValues})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, keys []orderKey) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var token cursorToken
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	if token.Order != orderSignature(keys) || len(token.Values) != len(keys) {
+		return nil, fmt.Errorf("cursor does not match the requested order")
+	}
+
+	// keep integers as integers so large ids are compared exactly
+	for i, value := range token.Values {
+		switch v := value.(type) {
+		case json.Number:
+			if integer, err := v.Int64(); err == nil {
+				token.Values[i] = integer
+			} else if float, err := v.Float64(); err == nil {
+				token.Values[i] = float
+			}
+		case map[string]any:
+			encoded, _ := v["data"].(string)
+			blob, err := base64.StdEncoding.DecodeString(encoded)
+			if v["$type"] != "blob" || err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			token.Values[i] = blob
+		}
+	}
+
+	return token.Values, nil
+}
+
+// buildKeysetCondition returns the condition selecting the rows ordered after the cursor values,
+// NULLs sort first in SQLite so they are handled with IS / IS NOT NULL instead of comparisons
+func buildKeysetCondition(keys []orderKey, values []any) (string, []any) {
+	var disjuncts []string
+	var params []any
+
+	for i, key := range keys {
+		var parts []string
+		var partParams []any
+
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s IS ?", keys[j].Column))
+			partParams = append(partParams, values[j])
+		}
+
+		value := values[i]
+		switch {
+		case !key.Descending && value == nil:
+			parts = append(parts, fmt.Sprintf("%s IS NOT NULL", key.Column))
+		case !key.Descending:
+			parts = append(parts, fmt.Sprintf("%s > ?", key.Column))
+			partParams = append(partParams, value)
+		case value == nil:
+			// nothing comes after NULL when sorting descending
+			continue
+		default:
+			parts = append(parts, fmt.Sprintf("(%s < ? OR %s IS NULL)", key.Column, key.Column))
+			partParams = append(partParams, value)
+		}
+
+		disjuncts = append(disjuncts, "("+strings.Join(parts, " AND ")+")")
+		params = append(params, partParams...)
+	}
+
+	if len(disjuncts) == 0 {
+		return "0", nil
+	}
+
+	return "(" + strings.Join(disjuncts, " OR ") + ")", params
+}
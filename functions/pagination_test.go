@@ -0,0 +1,92 @@
+package functions
+
+import (
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// pageThrough follows next cursors until the last page and returns the ids in the order they came back
+func pageThrough(t *testing.T, selectModel models.SelectModel) []int64 {
+	t.Helper()
+
+	var ids []int64
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("pagination did not end")
+		}
+
+		rows, next, err := selectRows(dbclass.DB, selectModel, 0)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		for _, row := range rows {
+			ids = append(ids, row["id"].(int64))
+		}
+
+		if next == "" {
+			return ids
+		}
+		selectModel.Cursor = next
+	}
+}
+
+func TestCursorPaginationOnTimeKeys(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE events (id INTEGER PRIMARY KEY, created_at DATETIME, day DATE)")
+	mustExec(t, `INSERT INTO events (id, created_at, day) VALUES
+		(1, '2024-01-01 12:00:00', '2024-01-03'),
+		(2, '2024-01-01 12:00:00', '2024-01-01'),
+		(3, '2024-01-02 08:30:00', '2024-01-02'),
+		(4, NULL, NULL),
+		(5, '2023-12-31 23:59:59', '2024-01-02')`)
+
+	tests := []struct {
+		name  string
+		order []models.OrderModel
+		want  []int64
+	}{
+		{"datetime asc", []models.OrderModel{{Column: "created_at"}}, []int64{4, 5, 1, 2, 3}},
+		{"datetime desc", []models.OrderModel{{Column: "created_at", Direction: "desc"}}, []int64{3, 1, 2, 5, 4}},
+		{"date asc", []models.OrderModel{{Column: "day"}}, []int64{4, 2, 3, 5, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := pageThrough(t, models.SelectModel{
+				TableName:       "events",
+				SelectedColumns: []string{"id", "created_at"},
+				Order:           tt.order,
+				Limit:           intPtr(2),
+			})
+
+			if len(ids) != len(tt.want) {
+				t.Fatalf("got ids %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("got ids %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorPaginationOnBlobKeys(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE files (id INTEGER PRIMARY KEY, hash BLOB)")
+	mustExec(t, "INSERT INTO files (id, hash) VALUES (1, x'03'), (2, x'01'), (3, x'02')")
+
+	ids := pageThrough(t, models.SelectModel{
+		TableName:       "files",
+		SelectedColumns: []string{"id"},
+		Order:           []models.OrderModel{{Column: "hash"}},
+		Limit:           intPtr(1),
+	})
+
+	want := []int64{2, 3, 1}
+	if len(ids) != len(want) || ids[0] != 2 || ids[1] != 3 || ids[2] != 1 {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,363 @@
+package functions
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+// queryOperators maps the PostgREST operator names (and the InlineDB ones) used in query strings to filter operators
+var queryOperators = map[string]string{
+	"eq": "eq", "neq": "ne", "ne": "ne", "gt": "gt", "gte": "gte", "lt": "lt", "lte": "lte",
+	"like": "like", "ilike": "ilike", "in": "in", "not_in": "not_in",
+	"match": "regex", "imatch": "regex", "regex": "regex",
+	"isdistinct": "is_distinct_from", "is_distinct_from": "is_distinct_from",
+	"starts_with": "starts_with", "ends_with": "ends_with", "contains": "contains", "cs": "array_contains", "array_contains": "array_contains", "between": "between",
+}
+
+// queryOptions collects the parsed parameters of the top level select or of one embedded relation
+type queryOptions struct {
+	where     []models.FilterExpression
+	order     []models.OrderModel
+	limit     *int
+	offset    *int
+	cursor    string
+	relations map[string]*queryOptions
+}
+
+// ParseSelectQuery maps a PostgREST style query string like ?select=id,name&age=gte.18&order=created_at.desc&limit=20
+// onto a SelectModel, parameters prefixed with a relation name ("comments.limit=5") apply to that embedded relation.
+// Parameters starting with an underscore that are not columns of the table, like the _=<timestamp> cache buster, are ignored
+func ParseSelectQuery(tableName string, query url.Values) (*models.SelectModel, error) {
+	selectModel := &models.SelectModel{
+		TableName:       tableName,
+		SelectedColumns: []string{"*"},
+	}
+
+	root := &queryOptions{}
+
+	// sort the keys so the same query string always builds the same statement
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var columnSet map[string]bool
+	for _, key := range keys {
+		if strings.HasPrefix(key, "_") {
+			if columnSet == nil {
+				var err error
+				if columnSet, err = getColumnSet(tableName); err != nil {
+					return nil, err
+				}
+			}
+
+			column, _, _ := strings.Cut(key, jsonArrow)
+			if !columnSet[column] {
+				continue
+			}
+		}
+
+		for _, value := range query[key] {
+			switch key {
+			case "table":
+				continue
+			case "select":
+				columns, err := splitQueryList(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid select: %v", err)
+				}
+				selectModel.SelectedColumns = columns
+				continue
+			case "offset":
+				offset, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid offset: %s", value)
+				}
+				root.offset = &offset
+				continue
+			case "cursor":
+				root.cursor = value
+				continue
+			}
+
+			// "comments.order" targets the embedded relation comments, "not.or" is a negated logic tree,
+			// the dots of a json path in a filter like meta->address.city are not relation names
+			name, jsonPath, isJSON := strings.Cut(key, jsonArrow)
+			path := strings.Split(name, ".")
+			if isJSON {
+				path[len(path)-1] += jsonArrow + jsonPath
+			}
+			options := root
+			for len(path) > 1 && path[0] != "not" {
+				if options.relations == nil {
+					options.relations = make(map[string]*queryOptions)
+				}
+				if options.relations[path[0]] == nil {
+					options.relations[path[0]] = &queryOptions{}
+				}
+				options = options.relations[path[0]]
+				path = path[1:]
+			}
+
+			if err := options.apply(strings.Join(path, "."), value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	selectModel.Where = combineExpressions(root.where)
+	selectModel.Order = root.order
+	selectModel.Limit = root.limit
+	selectModel.Offset = root.offset
+	selectModel.Cursor = root.cursor
+	selectModel.Relations = root.relationModels()
+
+	return selectModel, nil
+}
+
+func (options *queryOptions) apply(key string, value string) error {
+	switch key {
+	case "order":
+		order, err := parseQueryOrder(value)
+		if err != nil {
+			return err
+		}
+		options.order = append(options.order, order...)
+	case "limit":
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid limit: %s", value)
+		}
+		options.limit = &limit
+	case "and", "or", "not.and", "not.or":
+		expression, err := parseQueryLogic(key, value)
+		if err != nil {
+			return err
+		}
+		options.where = append(options.where, *expression)
+	default:
+		expression, err := parseQueryFilter(key, value)
+		if err != nil {
+			return err
+		}
+		options.where = append(options.where, *expression)
+	}
+
+	return nil
+}
+
+func (options *queryOptions) relationModels() map[string]models.RelationModel {
+	if len(options.relations) == 0 {
+		return nil
+	}
+
+	relations := make(map[string]models.RelationModel)
+	for name, child := range options.relations {
+		relations[name] = models.RelationModel{
+			Where:     combineExpressions(child.where),
+			Order:     child.order,
+			Limit:     child.limit,
+			Relations: child.relationModels(),
+		}
+	}
+
+	return relations
+}
+
+func combineExpressions(expressions []models.FilterExpression) *models.FilterExpression {
+	switch len(expressions) {
+	case 0:
+		return nil
+	case 1:
+		return &expressions[0]
+	default:
+		return &models.FilterExpression{And: expressions}
+	}
+}
+
+// parseQueryOrder parses "created_at.desc,name" into order items
+func parseQueryOrder(value string) ([]models.OrderModel, error) {
+	var order []models.OrderModel
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ".")
+		if len(parts) > 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid order: %s", item)
+		}
+
+		direction := "asc"
+		if len(parts) == 2 {
+			direction = parts[1]
+		}
+		order = append(order, models.OrderModel{Column: parts[0], Direction: direction})
+	}
+	return order, nil
+}
+
+// parseQueryFilter parses a column filter like age=gte.18 or name=not.in.(a,b)
+func parseQueryFilter(column string, value string) (*models.FilterExpression, error) {
+	negate := false
+	if strings.HasPrefix(value, "not.") {
+		negate = true
+		value = strings.TrimPrefix(value, "not.")
+	}
+
+	operator, operand, found := strings.Cut(value, ".")
+	if !found {
+		return nil, fmt.Errorf("invalid filter for '%s', expected operator.value", column)
+	}
+
+	condition := models.FilterCondition{Column: column}
+
+	switch operator {
+	case "is":
+		switch strings.ToLower(operand) {
+		case "null":
+			condition.Operator = "is_null"
+		case "true":
+			condition.Operator, condition.Value = "eq", true
+		case "false":
+			condition.Operator, condition.Value = "eq", false
+		default:
+			return nil, fmt.Errorf("invalid is filter for '%s', use null, true or false", column)
+		}
+	case "in", "not_in", "between":
+		if !strings.HasPrefix(operand, "(") || !strings.HasSuffix(operand, ")") {
+			return nil, fmt.Errorf("%s filter for '%s' should be a list like (a,b)", operator, column)
+		}
+
+		items, err := splitQueryList(operand[1 : len(operand)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s filter for '%s': %v", operator, column, err)
+		}
+
+		values := make([]any, len(items))
+		for i, item := range items {
+			values[i] = item
+		}
+		condition.Operator, condition.Value = queryOperators[operator], values
+	default:
+		mapped, ok := queryOperators[operator]
+		if !ok {
+			return nil, fmt.Errorf("invalid operator '%s' for '%s'", operator, column)
+		}
+
+		switch operator {
+		case "like", "ilike":
+			// PostgREST uses * as the wildcard because % has to be encoded in urls
+			operand = strings.ReplaceAll(operand, "*", "%")
+		case "imatch":
+			operand = "(?i)" + operand
+		}
+		condition.Operator, condition.Value = mapped, unquoteQueryValue(operand)
+	}
+
+	expression := &models.FilterExpression{FilterCondition: condition}
+	if negate {
+		return &models.FilterExpression{Not: expression}, nil
+	}
+	return expression, nil
+}
+
+// parseQueryLogic parses logic trees like or=(age.lt.18,and(name.eq.bob,age.gt.21))
+func parseQueryLogic(key string, value string) (*models.FilterExpression, error) {
+	negate := strings.HasPrefix(key, "not.")
+	logic := strings.TrimPrefix(key, "not.")
+
+	if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+		return nil, fmt.Errorf("%s filter should be a list like (a.eq.1,b.eq.2)", key)
+	}
+
+	items, err := splitQueryList(value[1 : len(value)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s filter: %v", key, err)
+	}
+
+	children := make([]models.FilterExpression, 0, len(items))
+	for _, item := range items {
+		child, err := parseQueryLogicItem(item)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, *child)
+	}
+
+	expression := &models.FilterExpression{And: children}
+	if logic == "or" {
+		expression = &models.FilterExpression{Or: children}
+	}
+
+	if negate {
+		return &models.FilterExpression{Not: expression}, nil
+	}
+	return expression, nil
+}
+
+// parseQueryLogicItem parses one item of a logic tree, either a nested and(...)/or(...) or column.operator.value
+func parseQueryLogicItem(item string) (*models.FilterExpression, error) {
+	for _, logic := range []string{"and", "or", "not.and", "not.or"} {
+		if strings.HasPrefix(item, logic+"(") {
+			return parseQueryLogic(logic, item[len(logic):])
+		}
+	}
+
+	column, filter, found := strings.Cut(item, ".")
+	if !found {
+		return nil, fmt.Errorf("invalid filter: %s", item)
+	}
+
+	return parseQueryFilter(column, filter)
+}
+
+// splitQueryList splits on the commas outside of parentheses and double quotes, quotes are removed from plain items
+func splitQueryList(list string) ([]string, error) {
+	var parts []string
+	var current strings.Builder
+	depth := 0
+	quoted := false
+
+	for _, char := range list {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case quoted:
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case char == ',' && depth == 0:
+			parts = append(parts, unquoteQueryValue(strings.TrimSpace(current.String())))
+			current.Reset()
+			continue
+		}
+		current.WriteRune(char)
+	}
+
+	if depth != 0 || quoted {
+		return nil, fmt.Errorf("unbalanced parentheses or quotes")
+	}
+
+	parts = append(parts, unquoteQueryValue(strings.TrimSpace(current.String())))
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty item in the list")
+		}
+	}
+
+	return parts, nil
+}
+
+func unquoteQueryValue(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
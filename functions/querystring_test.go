@@ -0,0 +1,66 @@
+package functions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+)
+
+func TestParseSelectQuery(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT, tags TEXT, meta TEXT, _hidden INTEGER)")
+	mustExec(t, `INSERT INTO posts (id, title, tags, meta, _hidden) VALUES
+		(1, 'first', '["go","sql"]', '{"items":[{"name":"a"},{"name":"b"}]}', 0),
+		(2, 'second', '["sql"]', '{"items":[{"name":"c"}]}', 1),
+		(3, 'third', '[]', '{}', 0)`)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    []int64
+		wantErr string
+	}{
+		{"numeric path segment", "tags->0=eq.sql", []int64{2}, ""},
+		{"bracket path segment", "tags->[1]=eq.sql", []int64{1}, ""},
+		{"numeric segment inside a path", "meta->items.1.name=eq.b", []int64{1}, ""},
+		{"cache buster is ignored", "_=1700000000123&title=neq.third", []int64{1, 2}, ""},
+		{"underscore column still filters", "_hidden=eq.1", []int64{2}, ""},
+		{"unknown column fails", "nope=eq.1", nil, "does not exist"},
+		{"array membership", "tags=cs.go", []int64{1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+
+			selectModel, err := ParseSelectQuery("posts", values)
+			if err == nil {
+				selectModel.SelectedColumns = []string{"id"}
+				var rows []map[string]any
+				rows, _, err = selectRows(dbclass.DB, *selectModel, 0)
+				if err == nil {
+					var ids []int64
+					for _, row := range rows {
+						ids = append(ids, row["id"].(int64))
+					}
+					if tt.wantErr == "" && fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+						t.Fatalf("got ids %v, want %v", ids, tt.want)
+					}
+				}
+			}
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package functions
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// referentialActions maps the actions accepted in a ReferenceModel to their SQL
+var referentialActions = map[string]string{
+	"no_action":   "NO ACTION",
+	"restrict":    "RESTRICT",
+	"set_null":    "SET NULL",
+	"set_default": "SET DEFAULT",
+	"cascade":     "CASCADE",
+}
+
+// normalizeAction returns the SQL of a referential action, empty when no action is given
+func normalizeAction(action string) (string, error) {
+	key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(action), " ", "_"))
+	if key == "" {
+		return "", nil
+	}
+
+	sqlAction, ok := referentialActions[key]
+	if !ok {
+		return "", fmt.Errorf("invalid referential action: %s, use no_action, restrict, set_null, set_default or cascade", action)
+	}
+	return sqlAction, nil
+}
+
+// actionName turns the SQL of a referential action reported by SQLite back into its ReferenceModel form
+func actionName(sqlAction string) string {
+	return strings.ToLower(strings.ReplaceAll(sqlAction, " ", "_"))
+}
+
+// validateReference checks the foreign key of a column of tableName and returns it with the target column filled in
+// and the actions in SQL form. columns are the columns of tableName, a table may reference itself. SQLite only
+// accepts a primary key or a column with a unique index as the target, anything else fails on every write
+func validateReference(tableName string, column models.ColumnModel, columns []models.ColumnModel) (*models.ReferenceModel, error) {
+	ref := *column.References
+	if err := ValidateColumnName(ref.Table); err != nil || ref.Table == "*" {
+		return nil, fmt.Errorf("invalid referenced table name: %s", ref.Table)
+	}
+
+	targetColumns := columns
+	if ref.Table != tableName {
+		if err := ValidateTableName(ref.Table); err != nil {
+			return nil, err
+		}
+
+		columnsPtr, err := GetTableColumns(ref.Table)
+		if err != nil {
+			return nil, err
+		}
+		targetColumns = *columnsPtr
+	}
+
+	var primaryKeys []string
+	found := false
+	for _, target := range targetColumns {
+		if target.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, target.Name)
+		}
+		if target.Name == ref.Column {
+			found = true
+		}
+	}
+
+	if ref.Column == "" {
+		if len(primaryKeys) != 1 {
+			return nil, fmt.Errorf("column '%s' has to name the referenced column, table '%s' has no single primary key", column.Name, ref.Table)
+		}
+		ref.Column = primaryKeys[0]
+		found = true
+	}
+
+	if !found {
+		return nil, fmt.Errorf("column '%s' does not exist in table '%s'", ref.Column, ref.Table)
+	}
+
+	isKey := len(primaryKeys) == 1 && primaryKeys[0] == ref.Column
+	if !isKey && ref.Table != tableName {
+		indexes, err := GetTableIndexes(ref.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, index := range indexes {
+			if index.Unique && index.Where == "" && len(index.Expressions) == 0 && len(index.Columns) == 1 && index.Columns[0] == ref.Column {
+				isKey = true
+				break
+			}
+		}
+	}
+	if !isKey {
+		return nil, fmt.Errorf("column '%s' of table '%s' cannot be referenced, it has to be the primary key or unique", ref.Column, ref.Table)
+	}
+
+	var err error
+	if ref.OnDelete, err = normalizeAction(ref.OnDelete); err != nil {
+		return nil, err
+	}
+	if ref.OnUpdate, err = normalizeAction(ref.OnUpdate); err != nil {
+		return nil, err
+	}
+
+	if (ref.OnDelete == "SET NULL" || ref.OnUpdate == "SET NULL") && !column.Nullable {
+		return nil, fmt.Errorf("column '%s' is not nullable so its reference cannot use set_null", column.Name)
+	}
+
+	return &ref, nil
+}
+
+// referenceClause renders the REFERENCES clause of a validated reference
+func referenceClause(ref *models.ReferenceModel) string {
+	clause := fmt.Sprintf("REFERENCES %s (%s)", ref.Table, ref.Column)
+	if ref.OnDelete != "" {
+		clause += " ON DELETE " + ref.OnDelete
+	}
+	if ref.OnUpdate != "" {
+		clause += " ON UPDATE " + ref.OnUpdate
+	}
+	return clause
+}
+
+// getColumnReferences returns the foreign keys of a table by column, the columns of a composite foreign key
+// each get the column they point to
+func getColumnReferences(tableName string) (map[string]*models.ReferenceModel, error) {
+	rows, err := dbclass.DB.Query(`SELECT "table", "from", "to", seq, on_update, on_delete FROM pragma_foreign_key_list(?)`, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+	}
+
+	references := make(map[string]*models.ReferenceModel)
+	implicit := make(map[string]int)
+	for rows.Next() {
+		var from, onUpdate, onDelete string
+		var to *string
+		var seq int
+		ref := &models.ReferenceModel{}
+		if err := rows.Scan(&ref.Table, &from, &to, &seq, &onUpdate, &onDelete); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		ref.OnUpdate = actionName(onUpdate)
+		ref.OnDelete = actionName(onDelete)
+		if to != nil {
+			ref.Column = *to
+		} else {
+			implicit[from] = seq
+		}
+		references[from] = ref
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// a foreign key written without target columns points to the primary key of the referenced table
+	for from, seq := range implicit {
+		ref := references[from]
+		err := dbclass.DB.QueryRow("SELECT name FROM pragma_table_info(?) WHERE pk = ?", ref.Table, seq+1).Scan(&ref.Column)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to resolve the foreign key of column %s: %w", from, err)
+		}
+	}
+
+	return references, nil
+}
+
+// getReferencingTables returns the other tables with a foreign key to tableName
+func getReferencingTables(tableName string) ([]string, error) {
+	rows, err := dbclass.DB.Query(`SELECT DISTINCT m.name FROM sqlite_schema m, pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table' AND f."table" = ? COLLATE NOCASE AND m.name != ? ORDER BY m.name`, tableName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
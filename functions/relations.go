@@ -0,0 +1,354 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// MaxEmbedDepth limits how deeply relations can be embedded inside each other
+const MaxEmbedDepth = 4
+
+// embedPattern matches "[alias:]table[!fk_column](columns)"
+var embedPattern = regexp.MustCompile(`^(?:([a-zA-Z_][a-zA-Z0-9_]*):)?([a-zA-Z_][a-zA-Z0-9_]*)(?:!([a-zA-Z_][a-zA-Z0-9_]*))?\((.*)\)$`)
+
+type embedSpec struct {
+	Name    string // key of the embedded value in the parent row
+	Table   string
+	Hint    string // foreign key column used when more than one relation links the two tables
+	Columns []string
+}
+
+type relation struct {
+	Many          bool   // one-to-many relations are embedded as arrays, many-to-one as a single object
+	LocalColumn   string // column of the parent table
+	ForeignColumn string // column of the embedded table
+}
+
+type foreignKey struct {
+	Table string // referenced table
+	From  string
+	To    string
+}
+
+// parseSelectColumns splits the selected columns into plain columns and embedded relations
+func parseSelectColumns(columns []string) ([]string, []embedSpec, error) {
+	var plain []string
+	var embeds []embedSpec
+
+	for _, column := range columns {
+		column = strings.TrimSpace(column)
+		if !strings.Contains(column, "(") {
+			plain = append(plain, column)
+			continue
+		}
+
+		match := embedPattern.FindStringSubmatch(column)
+		if match == nil {
+			return nil, nil, fmt.Errorf("invalid embedded relation: %s", column)
+		}
+
+		innerColumns, err := splitTopLevel(match[4])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid embedded relation %s: %v", column, err)
+		}
+
+		name := match[1]
+		if name == "" {
+			name = match[2]
+		}
+
+		embeds = append(embeds, embedSpec{
+			Name:    name,
+			Table:   match[2],
+			Hint:    match[3],
+			Columns: innerColumns,
+		})
+	}
+
+	return plain, embeds, nil
+}
+
+// splitTopLevel splits "a,b(c,d),e" on the commas that are not inside parentheses
+func splitTopLevel(list string) ([]string, error) {
+	var parts []string
+	depth := 0
+	start := 0
+
+	for i, char := range list {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+
+	last := strings.TrimSpace(list[start:])
+	if last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty column in the list")
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no columns specified")
+	}
+
+	return parts, nil
+}
+
+func getForeignKeys(tableName string) ([]foreignKey, error) {
+	rows, err := dbclass.DB.Query(`SELECT "table", "from", "to" FROM pragma_foreign_key_list(?)`, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []foreignKey
+	for rows.Next() {
+		var key foreignKey
+		var to *string
+		if err := rows.Scan(&key.Table, &key.From, &to); err != nil {
+			return nil, err
+		}
+		if to != nil {
+			key.To = *to
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// primaryKeyColumn returns the single primary key column of a table, a foreign key without a target column refers to it
+func primaryKeyColumn(tableName string) (string, error) {
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	var primaryKeys []string
+	for _, column := range *columnsPtr {
+		if column.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, column.Name)
+		}
+	}
+
+	if len(primaryKeys) != 1 {
+		return "", fmt.Errorf("table '%s' should have exactly one primary key column", tableName)
+	}
+
+	return primaryKeys[0], nil
+}
+
+// resolveRelation finds the foreign key linking the parent table and the embedded table using pragma_foreign_key_list,
+// a key on the parent gives a many-to-one relation and a key on the embedded table gives a one-to-many relation
+func resolveRelation(parentTable string, embed embedSpec) (*relation, error) {
+	if err := ValidateTableName(embed.Table); err != nil {
+		return nil, err
+	}
+
+	var candidates []relation
+
+	parentKeys, err := getForeignKeys(parentTable)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range parentKeys {
+		if key.Table != embed.Table || (embed.Hint != "" && key.From != embed.Hint) {
+			continue
+		}
+
+		to := key.To
+		if to == "" {
+			if to, err = primaryKeyColumn(embed.Table); err != nil {
+				return nil, err
+			}
+		}
+		candidates = append(candidates, relation{Many: false, LocalColumn: key.From, ForeignColumn: to})
+	}
+
+	embedKeys, err := getForeignKeys(embed.Table)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range embedKeys {
+		if key.Table != parentTable || (embed.Hint != "" && key.From != embed.Hint) {
+			continue
+		}
+
+		to := key.To
+		if to == "" {
+			if to, err = primaryKeyColumn(parentTable); err != nil {
+				return nil, err
+			}
+		}
+		candidates = append(candidates, relation{Many: true, LocalColumn: to, ForeignColumn: key.From})
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no foreign key relation found between '%s' and '%s'", parentTable, embed.Table)
+	case 1:
+		return &candidates[0], nil
+	default:
+		return nil, fmt.Errorf("more than one relation found between '%s' and '%s', pick the foreign key column with %s!fk_column(...)", parentTable, embed.Table, embed.Table)
+	}
+}
+
+// the columns a select adds for its relations all start with relationColumnPrefix. relationJoinPrefix aliases
+// the join columns of the parent rows, relationKeyColumn and relationRowColumn are added to every related row,
+// the parent value the row belongs to and the position of the row among the rows of that parent
+const (
+	relationColumnPrefix = "__relation_"
+	relationJoinPrefix   = relationColumnPrefix + "join_"
+	relationKeyColumn    = relationColumnPrefix + "key"
+	relationRowColumn    = relationColumnPrefix + "row"
+)
+
+// relationBatchSize is how many parent values are selected per query, it keeps the IN list well under the
+// SQLite variable limit when MAX_SELECT_DOCUMENTS is raised
+const relationBatchSize = 500
+
+// relationPartition restricts a select to the rows related to a set of parent rows
+type relationPartition struct {
+	Column string // the column of the embedded table holding the parent value
+	Values []any  // the distinct parent values
+}
+
+// buildPartitionQuery selects the rows of every parent in one query, ROW_NUMBER numbers the rows of each parent
+// in the requested order so the limit applies per parent
+func buildPartitionQuery(selectModel models.SelectModel, selected []string, whereClause string, params []any, keys []orderKey, partition *relationPartition, limit int) (*selectQuery, error) {
+	if err := ValidateColumns(selectModel.TableName, []string{partition.Column}); err != nil {
+		return nil, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(partition.Values)), ",")
+	inClause := fmt.Sprintf("%s IN (%s)", partition.Column, placeholders)
+	if whereClause != "" {
+		whereClause = "(" + whereClause + ") AND " + inClause
+	} else {
+		whereClause = inClause
+	}
+	params = append(params, partition.Values...)
+
+	// the unary + keeps the key as stored so it matches the raw parent value
+	selected = append(selected,
+		fmt.Sprintf("+%s AS %s", partition.Column, relationKeyColumn),
+		fmt.Sprintf("ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS %s", partition.Column, buildOrderByClause(keys), relationRowColumn),
+	)
+
+	query := fmt.Sprintf("SELECT * FROM (SELECT %s FROM %s WHERE %s) WHERE %s <= ? ORDER BY %s",
+		strings.Join(selected, ", "), selectModel.TableName, whereClause, relationRowColumn, relationRowColumn)
+	params = append(params, limit+1)
+
+	return &selectQuery{Query: query, Params: params, Limit: limit}, nil
+}
+
+// relationValueKey is how raw parent and related values are matched in Go, as text like SQLite's = does for
+// a TEXT column pointing at an INTEGER key. A blob never equals text, the NUL byte keeps the two apart
+func relationValueKey(value any) string {
+	if data, ok := value.([]byte); ok {
+		return "\x00" + string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// embedRelation loads the embedded rows of one relation into every parent row with one select per relationBatchSize
+// parents, a parent with more related rows than MaxSelectDocuments fails the request instead of being cut off silently.
+// parentValues are the raw values of the join column of the parent rows, in the same order
+func embedRelation(db dbclass.Querier, rows []map[string]any, parentValues []any, embed embedSpec, rel *relation, options models.RelationModel, depth int) error {
+	var values []any
+	seen := make(map[string]bool)
+	for _, value := range parentValues {
+		if value == nil || seen[relationValueKey(value)] {
+			continue
+		}
+		seen[relationValueKey(value)] = true
+		values = append(values, value)
+	}
+
+	childModel := models.SelectModel{
+		TableName:       embed.Table,
+		SelectedColumns: embed.Columns,
+		Filters:         options.Filters,
+		Where:           options.Where,
+		Order:           options.Order,
+		Limit:           options.Limit,
+		Relations:       options.Relations,
+	}
+
+	if !rel.Many {
+		one := 1
+		childModel.Limit = &one
+	}
+
+	limit, _, err := resolvePageSize(childModel, true)
+	if err != nil {
+		return fmt.Errorf("relation '%s': %w", embed.Name, err)
+	}
+
+	related := make(map[string][]map[string]any)
+	for start := 0; start < len(values); start += relationBatchSize {
+		end := min(start+relationBatchSize, len(values))
+		children, hidden, _, err := selectPartitionRows(db, childModel, depth+1, &relationPartition{Column: rel.ForeignColumn, Values: values[start:end]})
+		if err != nil {
+			return fmt.Errorf("relation '%s': %w", embed.Name, err)
+		}
+
+		for i, child := range children {
+			key := relationValueKey(hidden[i][relationKeyColumn])
+			related[key] = append(related[key], child)
+		}
+	}
+
+	// a limit the client asked for cuts the related rows on purpose, the MaxSelectDocuments cap does not
+	capped := childModel.Limit == nil || *childModel.Limit > limit
+	for key, children := range related {
+		if len(children) <= limit {
+			continue
+		}
+		if capped && rel.Many {
+			return fmt.Errorf("relation '%s' has more than %d rows for one parent, set a limit of at most %d in relations", embed.Name, limit, limit)
+		}
+		related[key] = children[:limit]
+	}
+
+	for i, row := range rows {
+		value := parentValues[i]
+		children := []map[string]any{}
+		if value != nil && related[relationValueKey(value)] != nil {
+			children = related[relationValueKey(value)]
+		}
+
+		if rel.Many {
+			row[embed.Name] = children
+		} else if len(children) > 0 {
+			row[embed.Name] = children[0]
+		} else {
+			row[embed.Name] = nil
+		}
+	}
+
+	return nil
+}
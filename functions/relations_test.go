@@ -0,0 +1,214 @@
+package functions
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func createLibraryTables(t *testing.T) {
+	t.Helper()
+	mustExec(t, "CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT)")
+	mustExec(t, "CREATE TABLE books (id INTEGER PRIMARY KEY, author_id INTEGER REFERENCES authors(id), title TEXT, published DATETIME, in_print BOOLEAN)")
+	mustExec(t, "CREATE TABLE reviews (id INTEGER PRIMARY KEY, book_id INTEGER REFERENCES books(id), stars INTEGER)")
+	mustExec(t, "INSERT INTO authors (id, name) VALUES (1, 'Ann'), (2, 'Bob'), (3, 'Cy')")
+	mustExec(t, `INSERT INTO books (id, author_id, title, published, in_print) VALUES
+		(10, 1, 'A1', '2020-01-01 00:00:00', 1),
+		(11, 1, 'A2', '2021-01-01 00:00:00', 0),
+		(12, 1, 'A3', '2022-01-01 00:00:00', 1),
+		(20, 2, 'B1', '2019-06-01 00:00:00', 1),
+		(30, NULL, 'Orphan', NULL, 0)`)
+	mustExec(t, "INSERT INTO reviews (id, book_id, stars) VALUES (1, 10, 5), (2, 10, 3), (3, 20, 4)")
+}
+
+func encodeRows(t *testing.T, rows []map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
+
+func TestEmbedRelations(t *testing.T) {
+	openTestDB(t)
+	createLibraryTables(t)
+
+	tests := []struct {
+		name  string
+		model models.SelectModel
+		want  string
+	}{
+		{
+			name: "one to many with per parent limit and order",
+			model: models.SelectModel{
+				TableName:       "authors",
+				SelectedColumns: []string{"name", "books(title,in_print)"},
+				Order:           []models.OrderModel{{Column: "id"}},
+				Relations: map[string]models.RelationModel{
+					"books": {Order: []models.OrderModel{{Column: "published", Direction: "desc"}}, Limit: intPtr(2)},
+				},
+			},
+			want: `[{"books":[{"in_print":true,"title":"A3"},{"in_print":false,"title":"A2"}],"name":"Ann"},` +
+				`{"books":[{"in_print":true,"title":"B1"}],"name":"Bob"},{"books":[],"name":"Cy"}]`,
+		},
+		{
+			name: "many to one",
+			model: models.SelectModel{
+				TableName:       "books",
+				SelectedColumns: []string{"title", "authors(name)"},
+				Order:           []models.OrderModel{{Column: "id"}},
+			},
+			want: `[{"authors":{"name":"Ann"},"title":"A1"},{"authors":{"name":"Ann"},"title":"A2"},{"authors":{"name":"Ann"},"title":"A3"},` +
+				`{"authors":{"name":"Bob"},"title":"B1"},{"authors":null,"title":"Orphan"}]`,
+		},
+		{
+			name: "nested relations and relation filters",
+			model: models.SelectModel{
+				TableName:       "authors",
+				SelectedColumns: []string{"name", "books(title,published,reviews(stars))"},
+				Where:           &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "id", Operator: "lt", Value: 3}},
+				Order:           []models.OrderModel{{Column: "id"}},
+				Relations: map[string]models.RelationModel{
+					"books": {
+						Where:     &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "title", Operator: "ne", Value: "A2"}},
+						Relations: map[string]models.RelationModel{"reviews": {Order: []models.OrderModel{{Column: "stars"}}}},
+					},
+				},
+			},
+			want: `[{"books":[{"published":"2020-01-01T00:00:00Z","reviews":[{"stars":3},{"stars":5}],"title":"A1"},` +
+				`{"published":"2022-01-01T00:00:00Z","reviews":[],"title":"A3"}],"name":"Ann"},` +
+				`{"books":[{"published":"2019-06-01T00:00:00Z","reviews":[{"stars":4}],"title":"B1"}],"name":"Bob"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, _, err := selectRows(dbclass.DB, tt.model, 0)
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if got := encodeRows(t, rows); got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedRelationReportsTruncation(t *testing.T) {
+	openTestDB(t)
+	createLibraryTables(t)
+	t.Setenv("MAX_SELECT_DOCUMENTS", "2")
+
+	model := models.SelectModel{
+		TableName:       "authors",
+		SelectedColumns: []string{"name", "books(title)"},
+		Where:           &models.FilterExpression{FilterCondition: models.FilterCondition{Column: "id", Operator: "eq", Value: 1}},
+	}
+
+	_, _, err := selectRows(dbclass.DB, model, 0)
+	if err == nil || !strings.Contains(err.Error(), "more than 2 rows for one parent") {
+		t.Fatalf("got error %v, want the truncation to be reported", err)
+	}
+
+	model.Relations = map[string]models.RelationModel{"books": {Limit: intPtr(1)}}
+	rows, _, err := selectRows(dbclass.DB, model, 0)
+	if err != nil {
+		t.Fatalf("an explicit limit should cut the relation: %v", err)
+	}
+	if books := rows[0]["books"].([]map[string]any); len(books) != 1 {
+		t.Fatalf("got %d books, want 1", len(books))
+	}
+}
+
+func TestEmbedRelationBatchesParents(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE parents (id INTEGER PRIMARY KEY)")
+	mustExec(t, "CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents(id))")
+	mustExec(t, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1200) INSERT INTO parents (id) SELECT i FROM n")
+	mustExec(t, "INSERT INTO children (parent_id) SELECT id FROM parents")
+
+	rows, _, err := selectRows(dbclass.DB, models.SelectModel{
+		TableName:       "parents",
+		SelectedColumns: []string{"id", "children(id)"},
+		Limit:           intPtr(1200),
+	}, 0)
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+
+	for _, row := range rows {
+		if children := row["children"].([]map[string]any); len(children) != 1 {
+			t.Fatalf("parent %v has %d children, want 1", row["id"], len(children))
+		}
+	}
+}
+
+func TestEmbedRelationMatchesRawJoinKeys(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE days (day DATETIME PRIMARY KEY, note TEXT)")
+	mustExec(t, "CREATE TABLE logs (id INTEGER PRIMARY KEY, day DATETIME REFERENCES days(day), message TEXT)")
+	mustExec(t, "CREATE TABLE files (hash BLOB PRIMARY KEY, name TEXT)")
+	mustExec(t, "CREATE TABLE refs (id INTEGER PRIMARY KEY, hash BLOB REFERENCES files(hash))")
+	mustExec(t, "INSERT INTO days (day, note) VALUES ('2024-01-01 00:00:00', 'new year'), ('2024-01-02 00:00:00', 'quiet')")
+	mustExec(t, "INSERT INTO logs (id, day, message) VALUES (1, '2024-01-01 00:00:00', 'a'), (2, '2024-01-01 00:00:00', 'b')")
+	mustExec(t, "INSERT INTO files (hash, name) VALUES (x'0102', 'one.txt'), (x'ff', 'two.txt')")
+	mustExec(t, "INSERT INTO refs (id, hash) VALUES (1, x'0102')")
+
+	tests := []struct {
+		name  string
+		model models.SelectModel
+		want  string
+	}{
+		{
+			name: "datetime one to many",
+			model: models.SelectModel{
+				TableName:       "days",
+				SelectedColumns: []string{"note", "logs(message)"},
+				Order:           []models.OrderModel{{Column: "day"}},
+			},
+			want: `[{"logs":[{"message":"a"},{"message":"b"}],"note":"new year"},{"logs":[],"note":"quiet"}]`,
+		},
+		{
+			name: "datetime many to one with the join column selected",
+			model: models.SelectModel{
+				TableName:       "logs",
+				SelectedColumns: []string{"day", "days(note)"},
+				Order:           []models.OrderModel{{Column: "id"}},
+			},
+			want: `[{"day":"2024-01-01T00:00:00Z","days":{"note":"new year"}},{"day":"2024-01-01T00:00:00Z","days":{"note":"new year"}}]`,
+		},
+		{
+			name: "blob one to many",
+			model: models.SelectModel{
+				TableName:       "files",
+				SelectedColumns: []string{"name", "refs(id)"},
+				Order:           []models.OrderModel{{Column: "name"}},
+			},
+			want: `[{"name":"one.txt","refs":[{"id":1}]},{"name":"two.txt","refs":[]}]`,
+		},
+		{
+			name: "blob many to one",
+			model: models.SelectModel{
+				TableName:       "refs",
+				SelectedColumns: []string{"id", "files(name)"},
+			},
+			want: `[{"files":{"name":"one.txt"},"id":1}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _, err := SelectFromTable(tt.model)
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("got  %s\nwant %s", data, tt.want)
+			}
+		})
+	}
+}
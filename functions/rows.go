@@ -0,0 +1,217 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+var ErrRowNotFound = errors.New("row not found")
+
+// rowKeyCondition validates the table and returns the primary key column with the condition matching the key,
+// the key from the url is normalized like a written value so an uppercase uuid finds its lowercased row
+func rowKeyCondition(tableName string, key string) (string, *models.FilterExpression, error) {
+	if err := ValidateTableName(tableName); err != nil {
+		return "", nil, err
+	}
+
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var primaryKeys []models.ColumnModel
+	for _, column := range *columnsPtr {
+		if column.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, column)
+		}
+	}
+
+	switch len(primaryKeys) {
+	case 0:
+		return "", nil, fmt.Errorf("per-row routes are not supported for table '%s', it has no primary key and is keyed by rowid", tableName)
+	case 1:
+	default:
+		return "", nil, fmt.Errorf("per-row routes are not supported for table '%s', it has a composite primary key", tableName)
+	}
+
+	primaryKey := primaryKeys[0]
+	value, err := normalizeLogicalValue(primaryKey, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return primaryKey.Name, &models.FilterExpression{
+		FilterCondition: models.FilterCondition{Column: primaryKey.Name, Operator: "eq", Value: value},
+	}, nil
+}
+
+// GetRowByKey returns one row by its primary key, columns may embed relations like in SelectFromTable
+func GetRowByKey(tableName string, key string, columns []string) (map[string]any, error) {
+	_, where, err := rowKeyCondition(tableName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return getRowWhere(dbclass.DB, tableName, where, columns)
+}
+
+// getRowWhere reads the first row matching where on db, which may be a transaction
+func getRowWhere(db dbclass.Querier, tableName string, where *models.FilterExpression, columns []string) (map[string]any, error) {
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+
+	one := 1
+	rows, _, err := selectRows(db, models.SelectModel{
+		TableName:       tableName,
+		SelectedColumns: columns,
+		Where:           where,
+		Limit:           &one,
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return rows[0], nil
+}
+
+// InsertRow inserts a single row given as a column -> value object
+func InsertRow(tableName string, values map[string]any) (*models.InsertResult, error) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	rowValues := make([]any, len(columns))
+	for i, column := range columns {
+		rowValues[i] = values[column]
+	}
+
+	return InsertIntoTable(models.InsertModel{
+		TableName: tableName,
+		Columns:   columns,
+		Values:    rowValues,
+	})
+}
+
+// GetInsertedRow returns the row written by an insert from the id reported in its result,
+// unlike GetRowByKey it also works for composite keys and tables keyed by rowid
+func GetInsertedRow(tableName string, id any) (map[string]any, error) {
+	key, err := resolveTableKey(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]any, len(key.Columns))
+	conditions := make([]string, len(key.Columns))
+	for i, column := range key.Columns {
+		conditions[i] = fmt.Sprintf("%s = ?", column)
+		params[i] = id
+		if composite, ok := id.(map[string]any); ok {
+			params[i] = composite[column]
+		}
+	}
+
+	jsonColumns, err := jsonColumnsOf(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := dbclass.DB.Query(fmt.Sprintf("SELECT * FROM %s WHERE %s LIMIT 1", tableName, strings.Join(conditions, " AND ")), params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	results, err := scanRows(rows, jsonColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return results[0], nil
+}
+
+// UpdateRowByKey updates one row by its primary key and returns the row after the update,
+// the update and the read back share a transaction so a concurrent write can't slip between them
+func UpdateRowByKey(tableName string, key string, values map[string]any) (map[string]any, error) {
+	primaryKey, where, err := rowKeyCondition(tableName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the update may have changed the key itself
+	updatedWhere := where
+	if newKey, ok := values[primaryKey]; ok && newKey != nil {
+		_, updatedWhere, err = rowKeyCondition(tableName, fmt.Sprint(newKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := dbclass.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	affected, err := updateTable(tx, models.UpdateModel{
+		TableName: tableName,
+		Values:    values,
+		Where:     where,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	row, err := getRowWhere(tx, tableName, updatedWhere, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return row, nil
+}
+
+// DeleteRowByKey deletes one row by its primary key and returns the deleted row
+func DeleteRowByKey(tableName string, key string) (map[string]any, error) {
+	_, where, err := rowKeyCondition(tableName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, deleted, err := DeleteFromTable(models.DeleteModel{
+		TableName: tableName,
+		Where:     where,
+		Returning: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deleted) == 0 {
+		return nil, ErrRowNotFound
+	}
+
+	return deleted[0], nil
+}
@@ -0,0 +1,102 @@
+package functions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestRowRoutesNormalizeUUIDKeys(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "devices",
+		KeyStrategy: "client",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "uuid", IsPrimaryKey: true},
+			{Name: "label", DataType: "txt", Nullable: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	const id = "123e4567-e89b-12d3-a456-426614174000"
+	if _, err := InsertRow("devices", map[string]any{"id": strings.ToUpper(id), "label": "a"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	row, err := GetRowByKey("devices", strings.ToUpper(id), nil)
+	if err != nil {
+		t.Fatalf("get with an uppercase key: %v", err)
+	}
+	if row["id"] != id {
+		t.Fatalf("got id %v, want the stored %s", row["id"], id)
+	}
+
+	if _, err := UpdateRowByKey("devices", strings.ToUpper(id), map[string]any{"label": "b"}); err != nil {
+		t.Fatalf("update with an uppercase key: %v", err)
+	}
+
+	deleted, err := DeleteRowByKey("devices", strings.ToUpper(id))
+	if err != nil {
+		t.Fatalf("delete with an uppercase key: %v", err)
+	}
+	if deleted["label"] != "b" {
+		t.Fatalf("deleted %v, want the updated row", deleted)
+	}
+
+	if _, err := GetRowByKey("devices", id, nil); !errors.Is(err, ErrRowNotFound) {
+		t.Fatalf("got %v, want ErrRowNotFound after the delete", err)
+	}
+}
+
+func TestRowRoutesRejectUnsupportedKeys(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE notes (body TEXT)")
+	mustExec(t, "CREATE TABLE memberships (user_id INTEGER, group_id INTEGER, PRIMARY KEY (user_id, group_id))")
+
+	tests := []struct {
+		table   string
+		wantErr string
+	}{
+		{"notes", "keyed by rowid"},
+		{"memberships", "composite primary key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			_, err := GetRowByKey(tt.table, "1", nil)
+			if err == nil || !strings.Contains(err.Error(), "per-row routes are not supported") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateRowByKeyReturnsTheRowUnderItsNewKey(t *testing.T) {
+	openTestDB(t)
+	mustExec(t, "CREATE TABLE tags (name TEXT PRIMARY KEY, uses INTEGER)")
+	mustExec(t, "INSERT INTO tags (name, uses) VALUES ('go', 1), ('golang', 2)")
+
+	row, err := UpdateRowByKey("tags", "go", map[string]any{"name": "gopher", "uses": 3})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if row["name"] != "gopher" || row["uses"] != int64(3) {
+		t.Fatalf("got %v, want the renamed row", row)
+	}
+
+	if _, err := UpdateRowByKey("tags", "missing", map[string]any{"uses": 1}); !errors.Is(err, ErrRowNotFound) {
+		t.Fatalf("got %v, want ErrRowNotFound", err)
+	}
+
+	// a failing update leaves the row as it was
+	if _, err := UpdateRowByKey("tags", "gopher", map[string]any{"name": "golang"}); err == nil {
+		t.Fatal("renaming onto an existing key should fail")
+	}
+	if _, err := GetRowByKey("tags", "gopher", nil); err != nil {
+		t.Fatalf("the row should keep its key after the failed update: %v", err)
+	}
+}
@@ -1,7 +1,6 @@
 package functions
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -15,15 +14,30 @@ import (
 // TYPE WHEN WE RETURN NOTHING
 // INSERT INTO <TABLE_NAME>(ID, NAME) VALUES(<VAL_1>,<VAL_2>);
 
+// IsSelectStatement reports if the raw statement returns rows and should go through QueryAsJson
+func IsSelectStatement(sqlStmt string) bool {
+	querySlice := strings.Split(strings.TrimSpace(sqlStmt), " ")
+	return strings.ToLower(strings.TrimSpace(querySlice[0])) == "select"
+}
+
+func isSchemaStatement(sqlStmt string) bool {
+	querySlice := strings.Fields(sqlStmt)
+	if len(querySlice) == 0 {
+		return false
+	}
+
+	switch strings.ToLower(querySlice[0]) {
+	case "create", "alter", "drop":
+		return true
+	}
+	return false
+}
+
 func RawSQL(sqlStmt string) (any, error) {
 
 	db := dbclass.DB
 
-	querySlice := strings.Split(sqlStmt, " ")
-	queryKeyword := strings.ToLower(strings.TrimSpace(querySlice[0]))
-	fmt.Println(queryKeyword)
-
-	if queryKeyword == "select" {
+	if IsSelectStatement(sqlStmt) {
 		data, err := QueryAsJson(sqlStmt)
 		if err != nil {
 			return nil, err
@@ -36,6 +50,13 @@ func RawSQL(sqlStmt string) (any, error) {
 		return nil, err
 	}
 
+	// CREATE, ALTER and DROP change the schema behind the admin metadata
+	if isSchemaStatement(sqlStmt) {
+		if err := ReconcileMetadata(); err != nil {
+			return nil, fmt.Errorf("statement executed but the table metadata could not be updated: %v", err)
+		}
+	}
+
 	successResult := map[string]any{"message": "success"}
 	return successResult, nil
 
@@ -47,75 +68,13 @@ func QueryAsJson(sqlStmt string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	columnTypes, err := rows.ColumnTypes()
-
+	finalRows, err := scanRows(rows, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	count := len(columnTypes)
-	finalRows := []interface{}{}
-
-	for rows.Next() {
-
-		scanArgs := make([]interface{}, count)
-
-		for i, v := range columnTypes {
-
-			switch v.DatabaseTypeName() {
-			case "VARCHAR", "TEXT", "UUID", "TIMESTAMP":
-				scanArgs[i] = new(sql.NullString)
-			case "BOOL":
-				scanArgs[i] = new(sql.NullBool)
-			case "NUMERIC", "INTEGER", "REAL":
-				scanArgs[i] = new(sql.NullInt64)
-			default:
-				scanArgs[i] = new(sql.NullString)
-			}
-		}
-
-		err := rows.Scan(scanArgs...)
-
-		if err != nil {
-			return nil, err
-		}
-
-		masterData := map[string]interface{}{}
-
-		for i, v := range columnTypes {
-
-			if z, ok := (scanArgs[i]).(*sql.NullBool); ok {
-				masterData[v.Name()] = z.Bool
-				continue
-			}
-
-			if z, ok := (scanArgs[i]).(*sql.NullString); ok {
-				masterData[v.Name()] = z.String
-				continue
-			}
-
-			if z, ok := (scanArgs[i]).(*sql.NullInt64); ok {
-				masterData[v.Name()] = z.Int64
-				continue
-			}
-
-			if z, ok := (scanArgs[i]).(*sql.NullFloat64); ok {
-				masterData[v.Name()] = z.Float64
-				continue
-			}
-
-			if z, ok := (scanArgs[i]).(*sql.NullInt32); ok {
-				masterData[v.Name()] = z.Int32
-				continue
-			}
-
-			masterData[v.Name()] = scanArgs[i]
-		}
-
-		finalRows = append(finalRows, masterData)
-	}
-
 	marshalData, err := json.Marshal(finalRows)
 
 	if err != nil {
@@ -0,0 +1,108 @@
+package functions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// RowSink receives the rows of a streamed query as they are scanned, Start is only called
+// once the first row is read (or the query ended without rows) so errors before that can
+// still be answered with a normal response
+type RowSink interface {
+	Start(columns []string) error
+	Row(values []any) error
+	End() error
+}
+
+// StreamSelect runs the select and writes every row to the sink without buffering the result,
+// it is not capped by MaxSelectDocuments and stops when ctx is cancelled (client disconnect)
+func StreamSelect(ctx context.Context, selectModel models.SelectModel, sink RowSink) error {
+	if len(strings.TrimSpace(selectModel.TableName)) == 0 {
+		return fmt.Errorf("you should enter the table name first to select")
+	}
+
+	if selectModel.Cursor != "" {
+		return fmt.Errorf("cursor pagination is not supported for streamed selects")
+	}
+
+	_, embeds, err := parseSelectColumns(selectModel.SelectedColumns)
+	if err != nil {
+		return err
+	}
+	if len(embeds) > 0 {
+		return fmt.Errorf("relations cannot be embedded in streamed selects")
+	}
+
+	built, err := buildSelectQuery(selectModel, true, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	jsonColumns, err := jsonColumnsOf(selectModel.TableName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := dbclass.DB.QueryContext(ctx, built.Query, built.Params...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	return streamRows(rows, jsonColumns, sink)
+}
+
+// StreamQuery is the streaming version of QueryAsJson for raw select statements
+func StreamQuery(ctx context.Context, sqlStmt string, sink RowSink) error {
+	rows, err := dbclass.DB.QueryContext(ctx, sqlStmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return streamRows(rows, nil, sink)
+}
+
+func streamRows(rows *sql.Rows, jsonColumns map[string]bool, sink RowSink) error {
+	encoder, err := NewRowEncoder(rows)
+	if err != nil {
+		return err
+	}
+	encoder.WithJSONColumns(jsonColumns)
+
+	started := false
+	for rows.Next() {
+		values, err := encoder.ScanRow(rows)
+		if err != nil {
+			return err
+		}
+
+		if !started {
+			if err := sink.Start(encoder.Columns()); err != nil {
+				return err
+			}
+			started = true
+		}
+
+		if err := sink.Row(values); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+
+	if !started {
+		if err := sink.Start(encoder.Columns()); err != nil {
+			return err
+		}
+	}
+
+	return sink.End()
+}
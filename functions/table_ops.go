@@ -0,0 +1,296 @@
+package functions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+// tableConstraintNamePattern finds the name of a table constraint definition of a CREATE TABLE statement
+var tableConstraintNamePattern = regexp.MustCompile(`(?i)^CONSTRAINT\s+([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// ErrNotConfirmed is returned when a destructive table action is missing its confirmation
+var ErrNotConfirmed = errors.New("confirmation required")
+
+// confirmTableAction checks that confirm repeats the name of the table a destructive action runs on
+func confirmTableAction(tableName string, confirm string, action string) error {
+	if confirm != tableName {
+		return fmt.Errorf("%w: set confirm to '%s' to %s the table", ErrNotConfirmed, tableName, action)
+	}
+	return nil
+}
+
+// validateNewName checks a name given to a new table or index, kind is what is named. Tables share their
+// namespace with indexes, views and triggers
+func validateNewName(kind string, name string) error {
+	if err := ValidateColumnName(name); err != nil || name == "*" {
+		return fmt.Errorf("invalid %s name format: %s", kind, name)
+	}
+
+	if strings.HasPrefix(strings.ToLower(name), "sqlite_") || strings.HasPrefix(name, rebuildPrefix) {
+		return fmt.Errorf("invalid %s name: %s, the name is reserved", kind, name)
+	}
+
+	var used string
+	err := dbclass.DB.QueryRow("SELECT type FROM sqlite_schema WHERE name = ? COLLATE NOCASE", name).Scan(&used)
+	if err == nil {
+		return fmt.Errorf("the name '%s' is already used by another %s", name, used)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return nil
+}
+
+// DropTable drops a table with its indexes and triggers and forgets its metadata
+func DropTable(dropModel models.DropTableModel) error {
+	tableName := dropModel.TableName
+	if err := ValidateTableName(tableName); err != nil {
+		return err
+	}
+
+	if err := confirmTableAction(tableName, dropModel.Confirm, "drop"); err != nil {
+		return err
+	}
+
+	// dropping a table deletes its rows first, which would run the ON DELETE actions of the tables referencing it
+	referencing, err := getReferencingTables(tableName)
+	if err != nil {
+		return err
+	}
+	if len(referencing) > 0 {
+		return fmt.Errorf("table '%s' is referenced by the foreign keys of %s, drop or change them first", tableName, strings.Join(referencing, ", "))
+	}
+
+	if err := execSchemaStatements(fmt.Sprintf("DROP TABLE %s", tableName)); err != nil {
+		return err
+	}
+
+	if err := dbclass.DeleteTableMetadata(tableName); err != nil {
+		return fmt.Errorf("table dropped but its metadata could not be removed: %v", err)
+	}
+
+	return nil
+}
+
+// RenameTable renames a table, SQLite updates the indexes, triggers and foreign keys that use it
+func RenameTable(renameModel models.RenameTableModel) error {
+	tableName := renameModel.TableName
+	if err := ValidateTableName(tableName); err != nil {
+		return err
+	}
+
+	if err := validateNewName("table", renameModel.NewName); err != nil {
+		return err
+	}
+
+	err := execSchemaStatements(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tableName, renameModel.NewName))
+	if err != nil {
+		return err
+	}
+
+	if err := dbclass.RenameTableMetadata(tableName, renameModel.NewName); err != nil {
+		return fmt.Errorf("table renamed but its metadata could not be moved: %v", err)
+	}
+
+	// the recorded schema still has the old name in it
+	return recordAlteredTable(renameModel.NewName, nil, nil)
+}
+
+// TruncateTable deletes every row of a table and returns how many were deleted, the AUTOINCREMENT counter
+// is reset when asked so new keys start from 1 again
+func TruncateTable(truncateModel models.TruncateTableModel) (int64, error) {
+	tableName := truncateModel.TableName
+	if err := ValidateTableName(tableName); err != nil {
+		return 0, err
+	}
+
+	if err := confirmTableAction(tableName, truncateModel.Confirm, "truncate"); err != nil {
+		return 0, err
+	}
+
+	tx, err := dbclass.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite has no TRUNCATE, a DELETE without WHERE is optimized into one when the table has no triggers
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", tableName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to truncate table: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if truncateModel.ResetAutoIncrement {
+		// sqlite_sequence only exists once a table with AUTOINCREMENT was created
+		var sequences int
+		err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_schema WHERE name = 'sqlite_sequence'").Scan(&sequences)
+		if err != nil {
+			return 0, err
+		}
+
+		if sequences > 0 {
+			if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", tableName); err != nil {
+				return 0, fmt.Errorf("failed to reset the autoincrement counter: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return affected, nil
+}
+
+// DuplicateTable creates a copy of a table with the same columns, constraints and indexes and records the copy
+// with the same metadata, the rows are copied when WithData is set. Triggers are not copied since they would
+// run their actions a second time for every write to the copy
+func DuplicateTable(duplicateModel models.DuplicateTableModel) error {
+	tableName := duplicateModel.TableName
+	newName := duplicateModel.NewName
+	if err := ValidateTableName(tableName); err != nil {
+		return err
+	}
+
+	if err := validateNewName("table", newName); err != nil {
+		return err
+	}
+
+	tableSQL, err := getTableSQL(tableName)
+	if err != nil {
+		return err
+	}
+
+	body, options, err := splitTableSQL(tableName, tableSQL)
+	if err != nil {
+		return err
+	}
+
+	body, err = duplicateTableBody(tableName, newName, body)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{fmt.Sprintf("CREATE TABLE %s (%s)%s", newName, body, options)}
+
+	if duplicateModel.WithData {
+		columnsPtr, err := GetTableColumns(tableName)
+		if err != nil {
+			return err
+		}
+
+		// generated columns are computed by the copy
+		var columns []string
+		for _, column := range *columnsPtr {
+			if column.Generated == nil {
+				columns = append(columns, column.Name)
+			}
+		}
+		columnList := strings.Join(columns, ", ")
+
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", newName, columnList, columnList, tableName))
+	}
+
+	indexes, err := duplicateIndexes(tableName, newName)
+	if err != nil {
+		return err
+	}
+	statements = append(statements, indexes...)
+
+	if err := execSchemaStatements(statements...); err != nil {
+		return err
+	}
+
+	key, err := resolveTableKey(tableName)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := dbclass.GetTableMetadata(tableName)
+	if err != nil {
+		return err
+	}
+
+	table := recordedTable(newName, metadata, nil)
+	table.KeyStrategy = key.Strategy
+	return recordTableMetadata(table)
+}
+
+// duplicateTableBody rewrites the definitions of a table for its copy, a foreign key of the table on itself points
+// at the copy and the table constraints CreateTable names after the table, like fk_<table>_<column>, are renamed
+func duplicateTableBody(tableName string, newName string, body string) (string, error) {
+	selfReference := regexp.MustCompile(`(?i)(\bREFERENCES\s+)["` + "`" + `\[]?` + regexp.QuoteMeta(tableName) + `["` + "`" + `\]]?(\s*\()`)
+
+	definitions, err := splitDefinitions(body)
+	if err != nil {
+		return "", err
+	}
+
+	for i, definition := range definitions {
+		definition = selfReference.ReplaceAllString(strings.TrimSpace(definition), "${1}"+newName+"${2}")
+
+		// column constraints are named after their column and stay as they are
+		if match := tableConstraintNamePattern.FindStringSubmatchIndex(definition); match != nil {
+			name := definition[match[2]:match[3]]
+			for _, prefix := range []string{"fk_" + tableName + "_", tableName + "_"} {
+				if strings.HasPrefix(name, prefix) {
+					definition = definition[:match[2]] + strings.Replace(name, tableName, newName, 1) + definition[match[3]:]
+					break
+				}
+			}
+		}
+
+		definitions[i] = definition
+	}
+
+	return strings.Join(definitions, ", "), nil
+}
+
+// duplicateIndexes returns the CREATE INDEX statements of a table rewritten for its copy, the copied index names
+// have the table name replaced or are prefixed with the new table name
+func duplicateIndexes(tableName string, newName string) ([]string, error) {
+	// the automatic indexes of UNIQUE and PRIMARY KEY constraints have no sql and come with the table definition
+	rows, err := dbclass.DB.Query("SELECT name, sql FROM sqlite_schema WHERE tbl_name = ? AND type = 'index' AND sql IS NOT NULL", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []string
+	for rows.Next() {
+		var name, indexSQL string
+		if err := rows.Scan(&name, &indexSQL); err != nil {
+			return nil, err
+		}
+
+		match := indexPattern.FindStringSubmatch(indexSQL)
+		if match == nil {
+			return nil, fmt.Errorf("could not parse index '%s' of table '%s'", name, tableName)
+		}
+
+		indexName := newName + "_" + name
+		if strings.Contains(name, tableName) {
+			indexName = strings.Replace(name, tableName, newName, 1)
+		}
+
+		if err := validateNewName("index", indexName); err != nil {
+			return nil, fmt.Errorf("could not copy index '%s': %v", name, err)
+		}
+
+		statements = append(statements, fmt.Sprintf("CREATE %sINDEX %s ON %s (%s", strings.ToUpper(match[1]), indexName, newName, match[4]))
+	}
+
+	return statements, rows.Err()
+}
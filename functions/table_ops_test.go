@@ -0,0 +1,59 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestDuplicateTablePointsSelfReferencesAtTheCopy(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "nodes",
+		KeyStrategy: "autoincrement",
+		Columns: []models.ColumnModel{
+			{Name: "id", DataType: "int", IsPrimaryKey: true},
+			{Name: "nodes_label", DataType: "txt", Nullable: true, Unique: true},
+			{Name: "parent_id", DataType: "int", Nullable: true, References: &models.ReferenceModel{Table: "nodes"}},
+		},
+		Uniques: []models.UniqueConstraintModel{{Columns: []string{"parent_id", "nodes_label"}}},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	mustExec(t, "INSERT INTO nodes (id, nodes_label, parent_id) VALUES (1, 'root', NULL), (2, 'leaf', 1)")
+
+	if err := DuplicateTable(models.DuplicateTableModel{TableName: "nodes", NewName: "nodes_copy", WithData: true}); err != nil {
+		t.Fatalf("duplicate: %v", err)
+	}
+
+	var target string
+	if err := dbclass.DB.QueryRow(`SELECT "table" FROM pragma_foreign_key_list('nodes_copy')`).Scan(&target); err != nil {
+		t.Fatalf("read foreign key: %v", err)
+	}
+	if target != "nodes_copy" {
+		t.Fatalf("the copy references %s, want nodes_copy", target)
+	}
+
+	tableSQL, err := getTableSQL("nodes_copy")
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	for _, want := range []string{"CONSTRAINT fk_nodes_copy_parent_id", "CONSTRAINT nodes_copy_parent_id_nodes_label_unique", "nodes_label"} {
+		if !strings.Contains(tableSQL, want) {
+			t.Fatalf("schema %s is missing %s", tableSQL, want)
+		}
+	}
+	if strings.Contains(tableSQL, "fk_nodes_parent_id") || strings.Contains(tableSQL, "nodes_copy_label") {
+		t.Fatalf("schema %s still uses the names of the original table or renamed a column", tableSQL)
+	}
+
+	// the copy is independent, deleting the original parent leaves it untouched
+	mustExec(t, "DELETE FROM nodes WHERE id = 2")
+	mustExec(t, "DELETE FROM nodes WHERE id = 1")
+	if _, err := dbclass.DB.Exec("DELETE FROM nodes_copy WHERE id = 1"); err == nil {
+		t.Fatal("deleting a parent row of the copy should fail on the copy's own foreign key")
+	}
+}
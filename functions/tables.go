@@ -3,6 +3,7 @@ package functions
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -10,9 +11,10 @@ import (
 	"github.com/MultiX0/db-test/constants"
 	dbclass "github.com/MultiX0/db-test/db"
 	"github.com/MultiX0/db-test/models"
-	"github.com/google/uuid"
 )
 
+var ErrTableNotFound = errors.New("table does not exist")
+
 func GetAllTables() (*models.TablesModel, error) {
 
 	var tables models.TablesModel
@@ -69,7 +71,7 @@ func GetTableData(tableName string) (*models.TableModel, error) {
 	err = stmt.QueryRow(tableName).Scan(&sqlValue)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
-			return nil, fmt.Errorf("this table does not exist")
+			return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
 		}
 		return nil, err
 	}
@@ -92,12 +94,43 @@ func GetTableData(tableName string) (*models.TableModel, error) {
 		return nil, err
 	}
 
-	return &models.TableModel{
+	key, err := resolveTableKey(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := GetTableIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := parseTableConstraints(tableName, sqlString)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := dbclass.GetTableMetadata(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &models.TableModel{
 		Name:         tableName,
 		Sql:          sqlString,
+		KeyStrategy:  key.Strategy,
 		Columns:      *columns,
+		Indexes:      indexes,
+		Uniques:      constraints.Uniques,
+		Checks:       constraints.Checks,
 		RecordsCount: *recordsCount,
-	}, nil
+	}
+
+	if metadata != nil {
+		table.Description = metadata.Description
+		table.CreatedAt = metadata.CreatedAt
+	}
+
+	return table, nil
 }
 
 func GetTableColumns(tableName string) (*[]models.ColumnModel, error) {
@@ -107,7 +140,9 @@ func GetTableColumns(tableName string) (*[]models.ColumnModel, error) {
 	}
 
 	var columns []models.ColumnModel
-	sqlStmt := fmt.Sprintf("SELECT name, type, pk, \"notnull\", dflt_value FROM pragma_table_info('%s')", tableName)
+	// pragma_table_xinfo also lists the generated columns, hidden is 2 for virtual and 3 for stored ones
+	// while 1 marks the hidden columns of virtual tables which are left out like pragma_table_info does
+	sqlStmt := fmt.Sprintf("SELECT name, type, pk, \"notnull\", dflt_value, hidden FROM pragma_table_xinfo('%s') WHERE hidden != 1", tableName)
 	rows, err := dbclass.DB.Query(sqlStmt)
 	if err != nil {
 		return nil, err
@@ -120,21 +155,31 @@ func GetTableColumns(tableName string) (*[]models.ColumnModel, error) {
 		var pk int
 		var notnull int
 		var default_value *string
+		var hidden int
 
-		err = rows.Scan(&name, &_type, &pk, &notnull, &default_value)
+		err = rows.Scan(&name, &_type, &pk, &notnull, &default_value, &hidden)
 		if err != nil {
 			return nil, err
 		}
 
 		// notnull = 1 means NOT NULL (nullable = false)
 		// notnull = 0 means NULL allowed (nullable = true)
-		columns = append(columns, models.ColumnModel{
+		column := models.ColumnModel{
 			Name:          name,
 			DataType:      _type,
-			IsPrimaryKey:  (pk == 1),
+			IsPrimaryKey:  (pk > 0),       // pk is the position of the column inside a composite key
 			Nullable:      (notnull == 0), // notnull=0 means nullable=true
 			Default_Value: default_value,
-		})
+		}
+
+		switch hidden {
+		case 2:
+			column.Generated = &models.GeneratedModel{Mode: GeneratedVirtual}
+		case 3:
+			column.Generated = &models.GeneratedModel{Mode: GeneratedStored}
+		}
+
+		columns = append(columns, column)
 	}
 
 	err = rows.Err()
@@ -142,6 +187,67 @@ func GetTableColumns(tableName string) (*[]models.ColumnModel, error) {
 		return nil, err
 	}
 
+	// logical types like uuid are not visible in pragma_table_info, they are marked by the constraints of the table
+	// or recorded in admin.db together with the column descriptions
+	var tableSQL sql.NullString
+	err = dbclass.DB.QueryRow("SELECT sql FROM sqlite_schema WHERE name = ? AND type = 'table'", tableName).Scan(&tableSQL)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	metadata, err := dbclass.GetTableMetadata(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the table metadata: %w", err)
+	}
+
+	references, err := getColumnReferences(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := parseTableConstraints(tableName, tableSQL.String)
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := parseGeneratedExpressions(tableName, tableSQL.String)
+	if err != nil {
+		return nil, err
+	}
+
+	logicalTypes := parseLogicalTypes(tableSQL.String)
+	enumValues := parseEnumValues(tableSQL.String)
+	for i := range columns {
+		column := &columns[i]
+		column.LogicalType = logicalTypes[column.Name]
+		column.EnumValues = enumValues[column.Name]
+		column.References = references[column.Name]
+		_, column.Unique = constraints.ColumnUniques[column.Name]
+		column.Check = constraints.ColumnChecks[column.Name].Expression
+		if column.Generated != nil {
+			column.Generated.Expression = generated[column.Name]
+		}
+
+		if metadata == nil {
+			continue
+		}
+
+		recorded, ok := metadata.Columns[column.Name]
+		if !ok {
+			continue
+		}
+
+		column.Description = recorded.Description
+
+		// a recorded logical type only applies while the column keeps the storage type it was recorded with
+		if column.LogicalType == "" && recorded.StorageType == column.DataType && isLogicalType(recorded.DataType) {
+			column.LogicalType = recorded.DataType
+		}
+		if column.LogicalType == LogicalTypeEnum && column.EnumValues == nil {
+			column.EnumValues = recorded.EnumValues
+		}
+	}
+
 	return &columns, nil
 }
 
@@ -182,12 +288,8 @@ func GetNumberOfTables() (*int, error) {
 
 // create table test(id integer not null primary key, name text)
 
-// on the dashboard make new datatype called uuid, this datatype is not part of sqlite by default but here is what we gonna do
-// make the column datatype to text
-// make it notnull and pk
-// add constraints that ensure the column length should be exact 36 character (uuid-length)
-// for any (insert - update - upsert) check the length of the id manually if it is implemented on the query , check if it is valid uuid or not
-// if the user dose not implement any id value that is fine just make it default uuid.V4
+// uuid columns are stored as TEXT with a 36 character CHECK constraint, a uuid primary key defaults to a random uuid v4
+// and the insert and update paths validate the values before they reach SQLite
 
 // Fixed CreateTable function with TEXT primary key and explicit index creation
 func CreateTable(table models.TableModel) error {
@@ -195,6 +297,43 @@ func CreateTable(table models.TableModel) error {
 		return fmt.Errorf("table name and columns are required")
 	}
 
+	columnSet := make(map[string]bool)
+	for _, column := range table.Columns {
+		columnSet[column.Name] = true
+	}
+
+	// constraints and foreign keys are checked before anything is created, a referenced table has to exist
+	// and the target has to be a key
+	references := make(map[string]*models.ReferenceModel)
+	for i, column := range table.Columns {
+		if err := validateColumnConstraints(column, columnSet); err != nil {
+			return err
+		}
+
+		if column.Generated != nil {
+			generated, err := validateGenerated(column, columnSet)
+			if err != nil {
+				return err
+			}
+			table.Columns[i].Generated = generated
+		}
+
+		if column.References == nil {
+			continue
+		}
+
+		ref, err := validateReference(table.Name, column, table.Columns)
+		if err != nil {
+			return err
+		}
+		references[column.Name] = ref
+	}
+
+	tableConstraints, err := buildTableConstraints(table)
+	if err != nil {
+		return err
+	}
+
 	// Start a transaction for atomic operations
 	tx, err := dbclass.DB.Begin()
 	if err != nil {
@@ -202,25 +341,42 @@ func CreateTable(table models.TableModel) error {
 	}
 	defer tx.Rollback()
 
+	// the key strategy decides how insert fills the primary key, tables without one get it inferred from their key columns
+	var keyColumns []models.ColumnModel
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			keyColumns = append(keyColumns, models.ColumnModel{
+				Name:        column.Name,
+				DataType:    constants.DataTypes[column.DataType],
+				LogicalType: logicalTypeOf(column.DataType),
+			})
+		}
+	}
+
+	keyStrategy := strings.ToLower(strings.TrimSpace(table.KeyStrategy))
+	if keyStrategy == "" {
+		keyStrategy = inferKeyStrategy(keyColumns)
+	} else if err := ValidateKeyStrategy(keyStrategy, keyColumns); err != nil {
+		return err
+	}
+
+	// an inferred integer key stays a plain rowid alias, only the requested strategy never reuses ids
+	autoIncrement := table.KeyStrategy != "" && keyStrategy == KeyStrategyAutoIncrement
+
 	var columns []string
 	var primaryKeys []string
 	var indexesToCreate []string
 
 	for _, column := range table.Columns {
-		var parts []string
-
 		// Get the SQLite data type from constants
 		sqlType := constants.DataTypes[column.DataType]
 
-		parts = append(parts, column.Name, sqlType)
-
-		if column.Default_Value != nil && len(strings.TrimSpace(*column.Default_Value)) != 0 {
-			parts = append(parts, "DEFAULT", string(*column.Default_Value))
-
-		}
+		definition := buildColumnDefinition(column)
 
-		if !column.Nullable {
-			parts = append(parts, "NOT NULL")
+		// AUTOINCREMENT is only allowed on a column level INTEGER PRIMARY KEY
+		if column.IsPrimaryKey && autoIncrement {
+			columns = append(columns, definition+" PRIMARY KEY AUTOINCREMENT")
+			continue
 		}
 
 		// Handle primary key
@@ -238,23 +394,34 @@ func CreateTable(table models.TableModel) error {
 			}
 		}
 
-		columns = append(columns, strings.Join(parts, " "))
+		columns = append(columns, definition)
 	}
 
 	// Add primary key constraint
-	var sqlStmt string
 	if len(primaryKeys) > 0 {
-		pkConstraint := fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", "))
-		sqlStmt = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, %s)",
-			table.Name,
-			strings.Join(columns, ", "),
-			pkConstraint)
-	} else {
-		sqlStmt = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
-			table.Name,
-			strings.Join(columns, ", "))
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
 	}
 
+	// foreign keys are named table constraints, the referencing columns get an index so cascades and
+	// the checks on deletes from the referenced table do not scan this table
+	for _, column := range table.Columns {
+		ref, ok := references[column.Name]
+		if !ok {
+			continue
+		}
+
+		columns = append(columns, fmt.Sprintf("CONSTRAINT fk_%s_%s FOREIGN KEY (%s) %s", table.Name, column.Name, column.Name, referenceClause(ref)))
+
+		if !column.IsPrimaryKey {
+			indexesToCreate = append(indexesToCreate, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s (%s)",
+				table.Name, column.Name, table.Name, column.Name))
+		}
+	}
+
+	columns = append(columns, tableConstraints...)
+
+	sqlStmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table.Name, strings.Join(columns, ", "))
+
 	fmt.Printf("Executing SQL: %s\n", sqlStmt)
 
 	// Create the table
@@ -278,49 +445,59 @@ func CreateTable(table models.TableModel) error {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	// admin.db is a separate database, the metadata is recorded once the table exists
+	recorded := table
+	recorded.KeyStrategy = keyStrategy
+	err = recordTableMetadata(recorded)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func InsertIntoTable(insertModel models.InsertModel) (string, error) {
-	for _, column := range insertModel.Columns {
-		if strings.TrimSpace(column) == "id" {
-			return "", fmt.Errorf("insert request should not contains the id, id is auto generated by the system and will be returned in the response")
-		}
+// buildColumnDefinition renders the column definition of a CreateTable column without the primary key,
+// which CreateTable adds as a table constraint
+func buildColumnDefinition(column models.ColumnModel) string {
+	parts := []string{column.Name, constants.DataTypes[column.DataType]}
+
+	if column.Generated != nil {
+		parts = append(parts, generatedClause(column.Generated))
+
+	} else if column.Default_Value != nil && len(strings.TrimSpace(*column.Default_Value)) != 0 {
+		parts = append(parts, "DEFAULT", string(*column.Default_Value))
+
+	} else if column.DataType == LogicalTypeUUID && column.IsPrimaryKey {
+		// a uuid key the client does not send gets a random uuid v4
+		parts = append(parts, "DEFAULT", uuidV4Default)
 	}
 
-	id := uuid.New()
+	if !column.Nullable {
+		parts = append(parts, "NOT NULL")
+	}
 
-	// Build the SQL with placeholders
-	placeholders := make([]string, len(insertModel.Values))
-	for i := range placeholders {
-		placeholders[i] = "?"
+	if column.DataType == LogicalTypeUUID {
+		parts = append(parts, uuidColumnConstraint(column.Name))
 	}
 
-	sqlStmt := fmt.Sprintf("INSERT INTO %s (id, %s) VALUES (?, %s)",
-		insertModel.TableName,
-		strings.Join(insertModel.Columns, ", "),
-		strings.Join(placeholders, ", "))
+	if column.DataType == LogicalTypeEnum {
+		parts = append(parts, enumColumnConstraint(column.Name, column.EnumValues))
+	}
 
-	// Prepare the statement
-	stmt, err := dbclass.DB.Prepare(sqlStmt)
-	if err != nil {
-		return "", fmt.Errorf("failed to prepare statement: %v", err)
+	if column.DataType == LogicalTypeJSON {
+		parts = append(parts, jsonColumnConstraint(column.Name))
 	}
-	defer stmt.Close()
 
-	// Prepare the arguments slice
-	args := make([]interface{}, len(insertModel.Values)+1)
-	args[0] = id.String() // First argument is the ID (UUID-V4)
-	for i, value := range insertModel.Values {
-		args[i+1] = value
+	// the constraints are named after the column so a violation can be traced back to it
+	if column.Unique && !column.IsPrimaryKey {
+		parts = append(parts, fmt.Sprintf("CONSTRAINT %s_unique UNIQUE", column.Name))
 	}
 
-	_, err = stmt.Exec(args...)
-	if err != nil {
-		return "", fmt.Errorf("failed to execute statement: %v", err)
+	if column.Check != "" {
+		parts = append(parts, fmt.Sprintf("CONSTRAINT %s_check CHECK (%s)", column.Name, column.Check))
 	}
 
-	return id.String(), nil
+	return strings.Join(parts, " ")
 }
 
 // ValidateColumnName checks if a column name is safe (no SQL injection)
@@ -366,6 +543,29 @@ func ValidateColumnName(columnName string) error {
 	return nil
 }
 
+// ValidateTableName checks that the table name is a safe identifier and that the table exists
+func ValidateTableName(tableName string) error {
+	tableName = strings.TrimSpace(tableName)
+	if len(tableName) == 0 {
+		return fmt.Errorf("you should enter the table name first")
+	}
+
+	if err := ValidateColumnName(tableName); err != nil || tableName == "*" {
+		return fmt.Errorf("invalid table name format: %s", tableName)
+	}
+
+	var exists int
+	err := dbclass.DB.QueryRow("SELECT COUNT(*) FROM sqlite_schema WHERE name = ? AND type='table'", tableName).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+
+	return nil
+}
+
 func ValidateColumns(tableName string, columns []string) error {
 
 	if len(columns) == 1 && columns[0] == "*" {
@@ -381,8 +581,17 @@ func ValidateColumns(tableName string, columns []string) error {
 		return fmt.Errorf("no column information returned for table: %s", tableName)
 	}
 
+	return validateColumnsOf(tableName, *columnsPtr, columns)
+}
+
+// validateColumnsOf is ValidateColumns for table columns the caller already resolved
+func validateColumnsOf(tableName string, tableColumns []models.ColumnModel, columns []string) error {
+	if len(columns) == 1 && columns[0] == "*" {
+		return nil
+	}
+
 	actualColumnSet := make(map[string]bool)
-	for _, col := range *columnsPtr {
+	for _, col := range tableColumns {
 		actualColumnSet[col.Name] = true
 	}
 
@@ -399,214 +608,342 @@ func ValidateColumns(tableName string, columns []string) error {
 	return nil
 }
 
-func ValidateOperator(operator string) error {
-	validOps := map[string]bool{
-		"eq": true, "ne": true, "gt": true, "lt": true,
-		"gte": true, "lte": true, "like": true, "in": true, "not_in": true,
-		"is_null": true, "is_not_null": true,
-	}
+type selectQuery struct {
+	Query  string
+	Params []any
+	Keys   []orderKey
+	Limit  int
+}
 
-	if !validOps[operator] {
-		return fmt.Errorf("invalid operator: %s", operator)
+func BuildSelectQuery(selectModel models.SelectModel) (string, []any, error) {
+	built, err := buildSelectQuery(selectModel, false, nil, nil)
+	if err != nil {
+		return "", nil, err
 	}
-	return nil
+
+	return built.Query, built.Params, nil
 }
 
-func BuildWhereClause(tableName string, filters []models.FilterGroup) (string, []any, error) {
-	if len(filters) == 0 {
-		return "", []any{}, nil
+// buildSelectQuery builds the paginated select, the order keys are selected again under
+// cursorColumnPrefix aliases and one extra row is fetched to know if there is a next page,
+// streamed selects skip both and are not capped by MaxSelectDocuments. A partition selects the related rows of
+// many parents at once, the limit then applies to each parent and one extra row per parent is fetched instead.
+// joinColumns are selected again under relationJoinPrefix aliases to load the embedded relations of the rows
+func buildSelectQuery(selectModel models.SelectModel, streaming bool, partition *relationPartition, joinColumns []string) (*selectQuery, error) {
+	if err := ValidateTableName(selectModel.TableName); err != nil {
+		return nil, err
 	}
 
-	columnsPtr, err := GetTableColumns(tableName)
+	whereClause, params, err := BuildWhere(selectModel.TableName, selectModel.Filters, selectModel.Where)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get table columns: %w", err)
+		return nil, err
 	}
 
-	actualColumnSet := make(map[string]bool)
-	for _, col := range *columnsPtr {
-		actualColumnSet[col.Name] = true
+	limit, offset, err := resolvePageSize(selectModel, !streaming)
+	if err != nil {
+		return nil, err
 	}
 
-	var whereParts []string
-	var params []any
-
-	for _, group := range filters {
-		if len(group.Conditions) == 0 {
-			continue
-		}
+	fetchLimit := limit + 1
+	if streaming {
+		fetchLimit = limit
+	}
 
-		logic := strings.ToUpper(group.Logic)
-		if logic != "AND" && logic != "OR" {
-			logic = "AND"
+	if IsAggregateSelect(selectModel) {
+		built, err := buildAggregateQuery(selectModel, whereClause, params)
+		if err != nil {
+			return nil, err
 		}
 
-		var conditionParts []string
+		built.Query += " LIMIT ? OFFSET ?"
+		built.Params = append(built.Params, fetchLimit, offset)
+		built.Limit = limit
+		return built, nil
+	}
 
-		for _, condition := range group.Conditions {
-			if err := ValidateColumnName(condition.Column); err != nil {
-				return "", nil, err
-			}
+	if selectModel.Having != nil {
+		return nil, fmt.Errorf("having requires aggregates or group_by")
+	}
 
-			if !actualColumnSet[condition.Column] {
-				return "", nil, fmt.Errorf("filter column '%s' does not exist in table '%s'", condition.Column, tableName)
-			}
+	if len(selectModel.SelectedColumns) == 0 {
+		return nil, fmt.Errorf("no columns specified")
+	}
 
-			if err := ValidateOperator(condition.Operator); err != nil {
-				return "", nil, err
-			}
+	selectedExpressions, selectedColumns, err := selectExpressions(selectModel.SelectedColumns)
+	if err != nil {
+		return nil, err
+	}
 
-			conditionSQL, conditionParams, err := buildCondition(condition)
-			if err != nil {
-				return "", nil, err
-			}
+	if err := ValidateColumns(selectModel.TableName, append(selectedColumns, joinColumns...)); err != nil {
+		return nil, err
+	}
 
-			conditionParts = append(conditionParts, conditionSQL)
-			params = append(params, conditionParams...)
-		}
+	for i, column := range joinColumns {
+		selectedExpressions = append(selectedExpressions, fmt.Sprintf("+%s AS %s%d", column, relationJoinPrefix, i))
+	}
 
-		if len(conditionParts) > 0 {
-			groupClause := "(" + strings.Join(conditionParts, " "+logic+" ") + ")"
-			whereParts = append(whereParts, groupClause)
-		}
+	keys, err := resolveOrderKeys(selectModel.TableName, selectModel.Order)
+	if err != nil {
+		return nil, err
 	}
 
-	whereClause := strings.Join(whereParts, " AND ")
-	return whereClause, params, nil
-}
+	if partition != nil {
+		return buildPartitionQuery(selectModel, selectedExpressions, whereClause, params, keys, partition, limit)
+	}
 
-func buildCondition(condition models.FilterCondition) (string, []any, error) {
-	column := condition.Column
-	operator := condition.Operator
-	value := condition.Value
-
-	switch operator {
-	case "eq":
-		return fmt.Sprintf("%s = ?", column), []any{value}, nil
-	case "ne":
-		return fmt.Sprintf("%s != ?", column), []any{value}, nil
-	case "gt":
-		return fmt.Sprintf("%s > ?", column), []any{value}, nil
-	case "lt":
-		return fmt.Sprintf("%s < ?", column), []any{value}, nil
-	case "gte":
-		return fmt.Sprintf("%s >= ?", column), []any{value}, nil
-	case "lte":
-		return fmt.Sprintf("%s <= ?", column), []any{value}, nil
-	case "like":
-		return fmt.Sprintf("%s LIKE ?", column), []any{value}, nil
-	case "is_null":
-		return fmt.Sprintf("%s IS NULL", column), []any{}, nil
-	case "is_not_null":
-		return fmt.Sprintf("%s IS NOT NULL", column), []any{}, nil
-	case "in":
-		values, ok := value.([]any)
-		if !ok {
-			return "", nil, fmt.Errorf("IN operator requires array of values")
-		}
-		if len(values) == 0 {
-			return "", nil, fmt.Errorf("IN operator requires at least one value")
+	if selectModel.Cursor != "" {
+		cursorValues, err := decodeCursor(selectModel.Cursor, keys)
+		if err != nil {
+			return nil, err
 		}
 
-		placeholders := strings.Repeat("?,", len(values))
-		placeholders = placeholders[:len(placeholders)-1]
-
-		return fmt.Sprintf("%s IN (%s)", column, placeholders), values, nil
-	case "not_in":
-		values, ok := value.([]any)
-		if !ok {
-			return "", nil, fmt.Errorf("NOT IN operator requires array of values")
+		keysetClause, keysetParams := buildKeysetCondition(keys, cursorValues)
+		if whereClause != "" {
+			whereClause = "(" + whereClause + ") AND " + keysetClause
+		} else {
+			whereClause = keysetClause
 		}
-		if len(values) == 0 {
-			return "", nil, fmt.Errorf("NOT IN operator requires at least one value")
+		params = append(params, keysetParams...)
+	}
+
+	selected := selectedExpressions
+	if !streaming {
+		for i, key := range keys {
+			// the unary + drops the declared type so the driver returns the stored value untouched,
+			// a DATETIME key would otherwise come back as a time.Time and no longer match the stored text
+			selected = append(selected, fmt.Sprintf("+%s AS %s%d", key.Column, cursorColumnPrefix, i))
 		}
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selected, ", "), selectModel.TableName)
 
-		placeholders := strings.Repeat("?,", len(values))
-		placeholders = placeholders[:len(placeholders)-1]
+	if whereClause != "" {
+		query += " WHERE " + whereClause
+	}
 
-		return fmt.Sprintf("%s NOT IN (%s)", column, placeholders), values, nil
-	default:
-		return "", nil, fmt.Errorf("unsupported operator: %s", operator)
+	if len(keys) > 0 {
+		query += " ORDER BY " + buildOrderByClause(keys)
 	}
+
+	query += " LIMIT ? OFFSET ?"
+	params = append(params, fetchLimit, offset)
+
+	return &selectQuery{Query: query, Params: params, Keys: keys, Limit: limit}, nil
 }
 
-func BuildSelectQuery(selectModel models.SelectModel) (string, []any, error) {
-	if len(selectModel.SelectedColumns) == 0 {
-		return "", nil, fmt.Errorf("no columns specified")
+// SelectFromTable returns the selected rows as JSON and the cursor of the next page, the cursor is empty on the last page
+func SelectFromTable(selectModel models.SelectModel) ([]byte, string, error) {
+	if len(strings.TrimSpace(selectModel.TableName)) == 0 {
+		return nil, "", fmt.Errorf("you should enter the table name first to select")
 	}
 
-	if err := ValidateColumns(selectModel.TableName, selectModel.SelectedColumns); err != nil {
-		return "", nil, err
+	results, nextCursor, err := selectRows(dbclass.DB, selectModel, 0)
+	if err != nil {
+		return nil, "", err
 	}
 
-	whereClause, params, err := BuildWhereClause(selectModel.TableName, selectModel.Filters)
+	jsonResult, err := json.Marshal(results)
 	if err != nil {
-		return "", nil, err
+		return nil, "", fmt.Errorf("failed to marshal results: %w", err)
 	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectModel.SelectedColumns, ", "), selectModel.TableName)
+	return jsonResult, nextCursor, nil
+}
+
+// selectRows runs the select and resolves its embedded relations, depth is the embedding level of the select
+func selectRows(db dbclass.Querier, selectModel models.SelectModel, depth int) ([]map[string]any, string, error) {
+	results, _, nextCursor, err := selectPartitionRows(db, selectModel, depth, nil)
+	return results, nextCursor, err
+}
 
-	if whereClause != "" {
-		query += " WHERE " + whereClause
+// selectPartitionRows is selectRows for an optional partition, a partitioned select returns up to limit + 1 rows
+// of every parent and leaves the per parent limit to the caller. The hidden columns of every row are returned
+// next to the rows, for a partition they hold the relationKeyColumn the row belongs to
+func selectPartitionRows(db dbclass.Querier, selectModel models.SelectModel, depth int, partition *relationPartition) ([]map[string]any, []map[string]any, string, error) {
+	plainColumns, embeds, err := parseSelectColumns(selectModel.SelectedColumns)
+	if err != nil {
+		return nil, nil, "", err
 	}
 
-	return query, params, nil
-}
+	embedNames := make(map[string]bool)
+	for _, embed := range embeds {
+		if embedNames[embed.Name] {
+			return nil, nil, "", fmt.Errorf("relation '%s' is embedded more than once, use an alias like alias:%s(...)", embed.Name, embed.Table)
+		}
+		embedNames[embed.Name] = true
+	}
 
-func SelectFromTable(selectModel models.SelectModel) ([]byte, error) {
-	if len(strings.TrimSpace(selectModel.TableName)) == 0 {
-		return nil, fmt.Errorf("you should enter the table name first to select")
+	for name := range selectModel.Relations {
+		if !embedNames[name] {
+			return nil, nil, "", fmt.Errorf("relation options given for '%s' which is not embedded in the columns", name)
+		}
+	}
+
+	relations := make([]*relation, len(embeds))
+	var joinColumns []string
+	if len(embeds) > 0 {
+		if depth >= MaxEmbedDepth {
+			return nil, nil, "", fmt.Errorf("relations cannot be embedded deeper than %d levels", MaxEmbedDepth)
+		}
+
+		if IsAggregateSelect(selectModel) {
+			return nil, nil, "", fmt.Errorf("relations cannot be embedded in aggregate selects")
+		}
+
+		if err := ValidateTableName(selectModel.TableName); err != nil {
+			return nil, nil, "", err
+		}
+
+		selectedSet := make(map[string]bool)
+		for _, column := range plainColumns {
+			selectedSet[column] = true
+		}
+
+		for i, embed := range embeds {
+			if selectedSet[embed.Name] {
+				return nil, nil, "", fmt.Errorf("relation name '%s' clashes with a selected column, use an alias like alias:%s(...)", embed.Name, embed.Table)
+			}
+
+			rel, err := resolveRelation(selectModel.TableName, embed)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			relations[i] = rel
+
+			// the join column is loaded raw even when the client selected it, the selected value is converted for JSON
+			joinColumns = append(joinColumns, rel.LocalColumn)
+		}
+
+		selectModel.SelectedColumns = plainColumns
 	}
 
-	query, params, err := BuildSelectQuery(selectModel)
+	built, err := buildSelectQuery(selectModel, false, partition, joinColumns)
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
 
-	stmt, err := dbclass.DB.Prepare(query)
+	stmt, err := db.Prepare(built.Query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(params...)
+	rows, err := stmt.Query(built.Params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, nil, "", fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
-	columns, err := rows.Columns()
+	jsonColumns, err := jsonColumnsOf(selectModel.TableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		return nil, nil, "", err
 	}
 
-	var results []map[string]any
+	results, hidden, err := scanPage(rows, jsonColumns)
+	if err != nil {
+		return nil, nil, "", err
+	}
 
-	for rows.Next() {
-		values := make([]any, len(columns))
-		scanArgs := make([]any, len(columns))
-		for i := range values {
-			scanArgs[i] = &values[i]
+	hasMore := partition == nil && len(results) > built.Limit
+	if hasMore {
+		results, hidden = results[:built.Limit], hidden[:built.Limit]
+	}
+
+	// aggregate selects have no order keys and are paginated with offset only
+	var nextCursor string
+	if hasMore && len(built.Keys) > 0 {
+		last := hidden[len(hidden)-1]
+		cursorValues := make([]any, len(built.Keys))
+		for i := range built.Keys {
+			cursorValues[i] = last[fmt.Sprintf("%s%d", cursorColumnPrefix, i)]
+		}
+
+		nextCursor, err = EncodeCursor(built.Keys, cursorValues)
+		if err != nil {
+			return nil, nil, "", err
 		}
+	}
 
-		if err := rows.Scan(scanArgs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+	for i, embed := range embeds {
+		parentValues := make([]any, len(hidden))
+		for j, row := range hidden {
+			parentValues[j] = row[fmt.Sprintf("%s%d", relationJoinPrefix, i)]
 		}
 
-		rowMap := make(map[string]any)
-		for i, col := range columns {
-			rowMap[col] = values[i]
+		if err := embedRelation(db, results, parentValues, embed, relations[i], selectModel.Relations[embed.Name], depth); err != nil {
+			return nil, nil, "", err
 		}
+	}
+
+	return results, hidden, nextCursor, nil
+}
+
+// isHiddenColumn reports the columns a select adds for itself, the cursor keys and the relation keys
+func isHiddenColumn(column string) bool {
+	return strings.HasPrefix(column, cursorColumnPrefix) || strings.HasPrefix(column, relationColumnPrefix)
+}
+
+// scanPage reads the rows like scanRows but keeps the hidden columns out of the encoded rows, their raw values
+// are returned per row so the next cursor and the relation keys hold exactly what is stored
+func scanPage(rows *sql.Rows, jsonColumns map[string]bool) ([]map[string]any, []map[string]any, error) {
+	encoder, err := NewRowEncoder(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoder.WithJSONColumns(jsonColumns)
+
+	results := []map[string]any{}
+	var hidden []map[string]any
+
+	for rows.Next() {
+		values, err := encoder.ScanRaw(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rowMap := make(map[string]any, len(values))
+		hiddenValues := make(map[string]any)
+		for i, column := range encoder.Columns() {
+			if isHiddenColumn(column) {
+				hiddenValues[column] = values[i]
+				continue
+			}
+			rowMap[column] = encoder.EncodeValue(i, values[i])
+		}
+
 		results = append(results, rowMap)
+		hidden = append(hidden, hiddenValues)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+		return nil, nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
-	jsonResult, err := json.Marshal(results)
+	return results, hidden, nil
+}
+
+// scanRows reads every remaining row into a column name -> value map using the shared RowEncoder,
+// jsonColumns are the json columns of the table the rows come from, nil for raw SQL
+func scanRows(rows *sql.Rows, jsonColumns map[string]bool) ([]map[string]any, error) {
+	encoder, err := NewRowEncoder(rows)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal results: %w", err)
+		return nil, err
+	}
+	encoder.WithJSONColumns(jsonColumns)
+
+	results := []map[string]any{}
+
+	for rows.Next() {
+		rowMap, err := encoder.ScanMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, rowMap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
-	return jsonResult, nil
+	return results, nil
 }
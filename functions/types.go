@@ -0,0 +1,286 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+	"github.com/google/uuid"
+)
+
+// logical types are stored with a SQLite storage type plus constraints, GetTableColumns reports them as logical_type
+const (
+	LogicalTypeUUID = "uuid"
+	LogicalTypeEnum = "enum"
+	LogicalTypeJSON = "json"
+)
+
+// uuidConstraintPattern finds the named CHECK constraints CreateTable puts on uuid columns, the column is read
+// from the check itself since a renamed column keeps the original constraint name
+var uuidConstraintPattern = regexp.MustCompile(`(?i)CONSTRAINT\s+[a-zA-Z0-9_]+_is_uuid\s+CHECK\s*\(\s*"?([a-zA-Z_][a-zA-Z0-9_]*)"?\s+IS\s+NULL`)
+
+// jsonConstraintPattern finds the json_valid CHECK constraints of json columns
+var jsonConstraintPattern = regexp.MustCompile(`(?i)CONSTRAINT\s+[a-zA-Z0-9_]+_is_json\s+CHECK\s*\(\s*"?([a-zA-Z_][a-zA-Z0-9_]*)"?\s+IS\s+NULL`)
+
+// enumConstraintPattern finds the CHECK constraints of enum columns up to the opening parenthesis of the allowed values
+var enumConstraintPattern = regexp.MustCompile(`(?i)CONSTRAINT\s+[a-zA-Z0-9_]+_is_enum\s+CHECK\s*\(\s*"?([a-zA-Z_][a-zA-Z0-9_]*)"?\s+IS\s+NULL\s+OR\s+"?[a-zA-Z_][a-zA-Z0-9_]*"?\s+IN\s*\(`)
+
+// uuidGlob matches the 8-4-4-4-12 hex text form of a uuid
+var uuidGlob = strings.Join([]string{
+	strings.Repeat("[0-9a-fA-F]", 8),
+	strings.Repeat("[0-9a-fA-F]", 4),
+	strings.Repeat("[0-9a-fA-F]", 4),
+	strings.Repeat("[0-9a-fA-F]", 4),
+	strings.Repeat("[0-9a-fA-F]", 12),
+}, "-")
+
+// uuidV4Default builds a random uuid v4 in plain SQL, the version nibble is 4 and the variant is one of 8, 9, a or b
+const uuidV4Default = "(lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || " +
+	"substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || " +
+	"substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))))"
+
+// uuidColumnConstraint keeps raw SQL writes to a uuid column valid, the constraint name marks the column as uuid
+func uuidColumnConstraint(column string) string {
+	return fmt.Sprintf("CONSTRAINT %s_is_uuid CHECK (%s IS NULL OR (length(%s) = 36 AND %s GLOB '%s'))",
+		column, column, column, column, uuidGlob)
+}
+
+// enumColumnConstraint limits an enum column to its allowed values, the values live in the schema so raw SQL
+// writes are checked as well
+func enumColumnConstraint(column string, values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+
+	return fmt.Sprintf("CONSTRAINT %s_is_enum CHECK (%s IS NULL OR %s IN (%s))", column, column, column, strings.Join(quoted, ", "))
+}
+
+// jsonColumnConstraint keeps raw SQL writes to a json column valid JSON
+func jsonColumnConstraint(column string) string {
+	return fmt.Sprintf("CONSTRAINT %s_is_json CHECK (%s IS NULL OR json_valid(%s))", column, column, column)
+}
+
+// isLogicalConstraint reports whether a constraint name marks a logical type rather than a user defined check
+func isLogicalConstraint(name string) bool {
+	return strings.HasSuffix(name, "_is_uuid") || strings.HasSuffix(name, "_is_enum") || strings.HasSuffix(name, "_is_json")
+}
+
+// validateEnumValues checks the allowed values of an enum column
+func validateEnumValues(column models.ColumnModel) error {
+	if len(column.EnumValues) == 0 {
+		return fmt.Errorf("enum column '%s' needs at least one allowed value in enum_values", column.Name)
+	}
+
+	seen := make(map[string]bool)
+	for _, value := range column.EnumValues {
+		if value == "" {
+			return fmt.Errorf("enum column '%s' cannot allow an empty value, make the column nullable instead", column.Name)
+		}
+		if seen[value] {
+			return fmt.Errorf("enum column '%s' lists the value '%s' more than once", column.Name, value)
+		}
+		seen[value] = true
+	}
+
+	return nil
+}
+
+func isLogicalType(dataType string) bool {
+	return dataType == LogicalTypeUUID || dataType == LogicalTypeEnum || dataType == LogicalTypeJSON
+}
+
+// logicalTypeOf returns the logical type of a CreateTable data type, empty for the plain SQLite types
+func logicalTypeOf(dataType string) string {
+	if isLogicalType(dataType) {
+		return dataType
+	}
+	return ""
+}
+
+// parseLogicalTypes reads the logical column types out of the CREATE TABLE statement of a table
+func parseLogicalTypes(tableSQL string) map[string]string {
+	types := make(map[string]string)
+	for _, match := range uuidConstraintPattern.FindAllStringSubmatch(tableSQL, -1) {
+		types[match[1]] = LogicalTypeUUID
+	}
+	for _, match := range jsonConstraintPattern.FindAllStringSubmatch(tableSQL, -1) {
+		types[match[1]] = LogicalTypeJSON
+	}
+	for column := range parseEnumValues(tableSQL) {
+		types[column] = LogicalTypeEnum
+	}
+	return types
+}
+
+// enumConstraint is where the enum constraint of a column sits in a CREATE TABLE statement
+type enumConstraint struct {
+	Column string
+	Values []string
+	Start  int // the start of CONSTRAINT
+	End    int // just after the parenthesis closing the CHECK
+}
+
+// findEnumConstraints locates the enum constraints of a CREATE TABLE statement or a column definition
+func findEnumConstraints(tableSQL string) []enumConstraint {
+	var constraints []enumConstraint
+	for _, match := range enumConstraintPattern.FindAllStringSubmatchIndex(tableSQL, -1) {
+		list := tableSQL[match[1]:]
+		listEnd := closingParenthesis(list)
+		if listEnd < 0 {
+			continue
+		}
+
+		checkEnd := strings.Index(list[listEnd+1:], ")")
+		if checkEnd < 0 {
+			continue
+		}
+
+		literals, err := splitDefinitions(list[:listEnd])
+		if err != nil {
+			continue
+		}
+
+		values := make([]string, 0, len(literals))
+		for _, literal := range literals {
+			literal = strings.TrimSpace(literal)
+			if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+				continue
+			}
+			values = append(values, strings.ReplaceAll(literal[1:len(literal)-1], "''", "'"))
+		}
+
+		constraints = append(constraints, enumConstraint{
+			Column: tableSQL[match[2]:match[3]],
+			Values: values,
+			Start:  match[0],
+			End:    match[1] + listEnd + 1 + checkEnd + 1,
+		})
+	}
+	return constraints
+}
+
+// parseEnumValues reads the allowed values of the enum columns out of a CREATE TABLE statement
+func parseEnumValues(tableSQL string) map[string][]string {
+	values := make(map[string][]string)
+	for _, constraint := range findEnumConstraints(tableSQL) {
+		values[constraint.Column] = constraint.Values
+	}
+	return values
+}
+
+// replaceEnumConstraint swaps the allowed values of an enum column in a CREATE TABLE statement or column definition
+func replaceEnumConstraint(tableSQL string, column string, values []string) (string, error) {
+	for _, constraint := range findEnumConstraints(tableSQL) {
+		if constraint.Column == column {
+			return tableSQL[:constraint.Start] + enumColumnConstraint(column, values) + tableSQL[constraint.End:], nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find the enum constraint of column '%s'", column)
+}
+
+// writeCheckedColumns returns every column of the table whose writes are checked by name,
+// the columns of a logical type and the generated columns
+func writeCheckedColumns(tableName string) (map[string]models.ColumnModel, error) {
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return writeCheckedColumnsOf(*columnsPtr), nil
+}
+
+// writeCheckedColumnsOf is writeCheckedColumns for table columns the caller already resolved
+func writeCheckedColumnsOf(columns []models.ColumnModel) map[string]models.ColumnModel {
+	types := make(map[string]models.ColumnModel)
+	for _, column := range columns {
+		if column.LogicalType != "" || column.Generated != nil {
+			types[column.Name] = column
+		}
+	}
+	return types
+}
+
+// jsonColumnsOf returns the json columns of a table, the logical type comes from the _is_json constraint
+// or admin.db since the columns are declared as TEXT
+func jsonColumnsOf(tableName string) (map[string]bool, error) {
+	columnsPtr, err := GetTableColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonColumns := make(map[string]bool)
+	for _, column := range *columnsPtr {
+		if column.LogicalType == LogicalTypeJSON {
+			jsonColumns[column.Name] = true
+		}
+	}
+	return jsonColumns, nil
+}
+
+// normalizeLogicalValue validates a value written to a column of a logical type and returns the value to store
+func normalizeLogicalValue(column models.ColumnModel, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch column.LogicalType {
+	case LogicalTypeUUID:
+		text, ok := value.(string)
+		if !ok || len(text) != 36 {
+			return nil, fmt.Errorf("column '%s' should be a uuid like 123e4567-e89b-12d3-a456-426614174000", column.Name)
+		}
+		if _, err := uuid.Parse(text); err != nil {
+			return nil, fmt.Errorf("column '%s' should be a valid uuid: %v", column.Name, err)
+		}
+		return strings.ToLower(text), nil
+
+	case LogicalTypeEnum:
+		text, ok := value.(string)
+		if ok {
+			for _, allowed := range column.EnumValues {
+				if text == allowed {
+					return text, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("column '%s' should be one of %s, got %v", column.Name, strings.Join(column.EnumValues, ", "), value)
+
+	case LogicalTypeJSON:
+		// a string is taken as JSON text, objects, arrays, numbers and booleans are stored as they were sent
+		if text, ok := value.(string); ok {
+			if !json.Valid([]byte(text)) {
+				return nil, fmt.Errorf("column '%s' should be valid JSON, send objects and arrays as they are or a string of JSON text", column.Name)
+			}
+			return text, nil
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' should be valid JSON: %v", column.Name, err)
+		}
+		return string(encoded), nil
+	}
+
+	return value, nil
+}
+
+// normalizeLogicalValues validates the values aligned to columns in place
+func normalizeLogicalValues(types map[string]models.ColumnModel, columns []string, values []any) error {
+	for i, name := range columns {
+		column, ok := types[name]
+		if !ok {
+			continue
+		}
+
+		value, err := normalizeLogicalValue(column, values[i])
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+	return nil
+}
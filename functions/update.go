@@ -0,0 +1,135 @@
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/models"
+)
+
+func UpdateTable(updateModel models.UpdateModel) (int64, error) {
+	return updateTable(dbclass.DB, updateModel)
+}
+
+func updateTable(db dbclass.Querier, updateModel models.UpdateModel) (int64, error) {
+	if err := ValidateTableName(updateModel.TableName); err != nil {
+		return 0, err
+	}
+
+	if len(updateModel.Values) == 0 && len(updateModel.Increment) == 0 && len(updateModel.JSON) == 0 {
+		return 0, fmt.Errorf("you should enter at least one column value to update")
+	}
+
+	// sort the columns so the generated statement is stable between requests
+	columns := make([]string, 0, len(updateModel.Values)+len(updateModel.Increment)+len(updateModel.JSON))
+	for column := range updateModel.Values {
+		if strings.TrimSpace(column) == "*" {
+			return 0, fmt.Errorf("update values cannot use '*' as a column")
+		}
+		columns = append(columns, column)
+	}
+	for column, amount := range updateModel.Increment {
+		if _, ok := updateModel.Values[column]; ok {
+			return 0, fmt.Errorf("column %s cannot be both set and incremented", column)
+		}
+		if _, ok := amount.(float64); !ok {
+			return 0, fmt.Errorf("increment for column %s must be a number", column)
+		}
+		columns = append(columns, column)
+	}
+	for column := range updateModel.JSON {
+		_, set := updateModel.Values[column]
+		_, incremented := updateModel.Increment[column]
+		if set || incremented {
+			return 0, fmt.Errorf("column %s cannot be both patched and set or incremented", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	if err := ValidateColumns(updateModel.TableName, columns); err != nil {
+		return 0, err
+	}
+
+	types, err := writeCheckedColumns(updateModel.TableName)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := validateWritableColumns(types, columns); err != nil {
+		return 0, err
+	}
+
+	values := make(map[string]any, len(updateModel.Values))
+	for column, value := range updateModel.Values {
+		if logicalColumn, ok := types[column]; ok {
+			value, err = normalizeLogicalValue(logicalColumn, value)
+			if err != nil {
+				return 0, err
+			}
+		}
+		values[column] = value
+	}
+
+	for column := range updateModel.JSON {
+		if types[column].LogicalType != LogicalTypeJSON {
+			return 0, fmt.Errorf("column %s is not a json column and cannot be patched", column)
+		}
+	}
+
+	whereClause, whereParams, err := BuildWhere(updateModel.TableName, updateModel.Filters, updateModel.Where)
+	if err != nil {
+		return 0, err
+	}
+
+	if whereClause == "" && !updateModel.AllowAll {
+		return 0, fmt.Errorf("update without filters will change every row, set allow_all to true if this is intended")
+	}
+
+	setParts := make([]string, len(columns))
+	params := make([]any, 0, len(columns)+len(whereParams))
+	for i, column := range columns {
+		if amount, ok := updateModel.Increment[column]; ok {
+			setParts[i] = fmt.Sprintf("%s = %s + ?", column, column)
+			params = append(params, amount)
+			continue
+		}
+		if operations, ok := updateModel.JSON[column]; ok {
+			patch, patchParams, err := buildJSONPatch(column, operations)
+			if err != nil {
+				return 0, err
+			}
+			setParts[i] = fmt.Sprintf("%s = %s", column, patch)
+			params = append(params, patchParams...)
+			continue
+		}
+		setParts[i] = fmt.Sprintf("%s = ?", column)
+		params = append(params, values[column])
+	}
+	params = append(params, whereParams...)
+
+	query := fmt.Sprintf("UPDATE %s SET %s", updateModel.TableName, strings.Join(setParts, ", "))
+	if whereClause != "" {
+		query += " WHERE " + whereClause
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(params...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return affected, nil
+}
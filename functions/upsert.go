@@ -0,0 +1,98 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+const (
+	ConflictDoNothing = "do_nothing"
+	ConflictMerge     = "merge"
+	ConflictUpdate    = "update"
+)
+
+const (
+	InsertStatusInserted = "inserted"
+	InsertStatusUpdated  = "updated"
+	InsertStatusSkipped  = "skipped"
+	InsertStatusFailed   = "failed"
+)
+
+// buildOnConflictClause compiles the on_conflict block of an insert into a SQLite upsert clause, merge overwrites
+// every inserted column except the conflict target, update only the listed ones. tableColumns are the columns
+// of the table the caller already resolved
+func buildOnConflictClause(insertModel models.InsertModel, tableColumns []models.ColumnModel) (string, error) {
+	onConflict := insertModel.OnConflict
+	if onConflict == nil {
+		return "", nil
+	}
+
+	if len(onConflict.Columns) == 0 {
+		return "", fmt.Errorf("on_conflict requires at least one conflict column")
+	}
+
+	if err := validateColumnsOf(insertModel.TableName, tableColumns, onConflict.Columns); err != nil {
+		return "", err
+	}
+
+	conflictSet := make(map[string]bool)
+	for _, column := range onConflict.Columns {
+		if column == "*" {
+			return "", fmt.Errorf("on_conflict columns cannot use '*'")
+		}
+		conflictSet[column] = true
+	}
+
+	action := strings.ToLower(strings.TrimSpace(onConflict.Action))
+	if action == "" {
+		action = ConflictMerge
+		if len(onConflict.Update) > 0 {
+			action = ConflictUpdate
+		}
+	}
+
+	target := fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(onConflict.Columns, ", "))
+
+	var updateColumns []string
+	switch action {
+	case ConflictDoNothing:
+		return target + " DO NOTHING", nil
+	case ConflictMerge:
+		for _, column := range insertModel.Columns {
+			if !conflictSet[column] {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+		// every inserted column is part of the conflict target, so there is nothing to merge
+		if len(updateColumns) == 0 {
+			return target + " DO NOTHING", nil
+		}
+	case ConflictUpdate:
+		if len(onConflict.Update) == 0 {
+			return "", fmt.Errorf("on_conflict update action requires the update columns")
+		}
+
+		insertedSet := make(map[string]bool)
+		for _, column := range insertModel.Columns {
+			insertedSet[column] = true
+		}
+
+		for _, column := range onConflict.Update {
+			if !insertedSet[column] {
+				return "", fmt.Errorf("on_conflict update column '%s' is not part of the inserted columns", column)
+			}
+		}
+		updateColumns = onConflict.Update
+	default:
+		return "", fmt.Errorf("invalid on_conflict action: %s", onConflict.Action)
+	}
+
+	setParts := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		setParts[i] = fmt.Sprintf("%s = excluded.%s", column, column)
+	}
+
+	return target + " DO UPDATE SET " + strings.Join(setParts, ", "), nil
+}
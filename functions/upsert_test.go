@@ -0,0 +1,64 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/MultiX0/db-test/models"
+)
+
+func TestUpsertReportsStatus(t *testing.T) {
+	openTestDB(t)
+	err := CreateTable(models.TableModel{
+		Name:        "stock",
+		KeyStrategy: "client",
+		Columns: []models.ColumnModel{
+			{Name: "sku", DataType: "txt", IsPrimaryKey: true},
+			{Name: "qty", DataType: "int", Nullable: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	upsert := func(qty int, action string) *models.InsertResult {
+		t.Helper()
+		result, err := InsertIntoTable(models.InsertModel{
+			TableName:  "stock",
+			Columns:    []string{"sku", "qty"},
+			Values:     []any{"A-1", qty},
+			OnConflict: &models.OnConflictModel{Columns: []string{"sku"}, Action: action},
+		})
+		if err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		qty    int
+		action string
+		want   string
+	}{
+		{"first write inserts", 1, ConflictMerge, InsertStatusInserted},
+		{"second write updates", 2, ConflictMerge, InsertStatusUpdated},
+		{"do nothing skips", 3, ConflictDoNothing, InsertStatusSkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := upsert(tt.qty, tt.action)
+			if result.Status != tt.want || result.ID != "A-1" {
+				t.Fatalf("got %+v, want status %s for A-1", result, tt.want)
+			}
+		})
+	}
+
+	row, err := GetRowByKey("stock", "A-1", nil)
+	if err != nil {
+		t.Fatalf("get row: %v", err)
+	}
+	if row["qty"] != int64(2) {
+		t.Fatalf("qty is %v, want the merged 2", row["qty"])
+	}
+}
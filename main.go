@@ -6,6 +6,8 @@ import (
 
 	"github.com/MultiX0/db-test/api"
 	dbclass "github.com/MultiX0/db-test/db"
+	"github.com/MultiX0/db-test/functions"
+	"github.com/MultiX0/db-test/utils"
 )
 
 func main() {
@@ -13,11 +15,20 @@ func main() {
 
 	fmt.Println("Starting...")
 
+	if err := utils.LoadEnv(".env"); err != nil {
+		log.Printf("could not load .env, using the defaults: %v", err)
+	}
+
 	err := dbclass.InitDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// pick up tables created or altered through raw SQL while the server was down
+	if err := functions.ReconcileMetadata(); err != nil {
+		log.Printf("could not reconcile the table metadata: %v", err)
+	}
+
 	server := api.NewAPIServer(":1212")
 	server.Run()
 
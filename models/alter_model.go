@@ -0,0 +1,10 @@
+package models
+
+type AlterTableModel struct {
+	TableName  string       `json:"table"`
+	Action     string       `json:"action"`           // add_column, rename_column, drop_column, alter_column or set_enum_values
+	Column     string       `json:"column"`           // the existing column for every action but add_column
+	NewName    string       `json:"new_name"`         // rename_column
+	Definition *ColumnModel `json:"definition"`       // the new column for add_column, the new type and constraints for alter_column
+	Values     []string     `json:"values,omitempty"` // the new allowed values for set_enum_values
+}
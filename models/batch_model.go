@@ -0,0 +1,21 @@
+package models
+
+import "encoding/json"
+
+type BatchModel struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation wraps one of the existing insert, update, delete or select models,
+// values inside the body can point at an earlier operation with {"$ref": "<ref>"}
+type BatchOperation struct {
+	Ref  string          `json:"ref"`
+	Type string          `json:"type"` // insert, update, delete or select
+	Body json.RawMessage `json:"body"`
+}
+
+type BatchResult struct {
+	Ref    string `json:"ref,omitempty"`
+	Type   string `json:"type"`
+	Result any    `json:"result"`
+}
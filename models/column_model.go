@@ -1,9 +1,32 @@
 package models
 
 type ColumnModel struct {
-	Name          string  `json:"name"`
-	DataType      string  `json:"data_type"`
-	IsPrimaryKey  bool    `json:"is_pk"`
-	Nullable      bool    `json:"nullable"`
-	Default_Value *string `json:"default_value"`
+	Name          string          `json:"name"`
+	DataType      string          `json:"data_type"`
+	LogicalType   string          `json:"logical_type,omitempty"` // set when the column type is not a plain SQLite type, e.g. uuid
+	EnumValues    []string        `json:"enum_values,omitempty"`  // the allowed values of an enum column
+	IsPrimaryKey  bool            `json:"is_pk"`
+	Nullable      bool            `json:"nullable"`
+	Default_Value *string         `json:"default_value"`
+	Description   string          `json:"description,omitempty"`
+	Unique        bool            `json:"unique,omitempty"`
+	Check         string          `json:"check,omitempty"`      // a CHECK expression on the column, e.g. "price > 0"
+	References    *ReferenceModel `json:"references,omitempty"` // the foreign key of the column
+	Generated     *GeneratedModel `json:"generated,omitempty"`  // set for a column computed from the other columns of its row
+}
+
+// GeneratedModel is the expression a generated column is computed from, a virtual column is computed when it is read
+// and a stored one when its row is written
+type GeneratedModel struct {
+	Expression string `json:"expression"`     // e.g. "qty * price" or "first_name || ' ' || last_name"
+	Mode       string `json:"mode,omitempty"` // virtual or stored, virtual by default
+}
+
+// ReferenceModel is the row of another table a column points to, the actions are those of SQLite:
+// no_action, restrict, set_null, set_default or cascade
+type ReferenceModel struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	OnDelete string `json:"on_delete,omitempty"`
+	OnUpdate string `json:"on_update,omitempty"`
 }
@@ -0,0 +1,13 @@
+package models
+
+// UniqueConstraintModel makes a combination of columns unique, a single unique column uses ColumnModel.Unique
+type UniqueConstraintModel struct {
+	Name    string   `json:"name,omitempty"`
+	Columns []string `json:"columns"`
+}
+
+// CheckConstraintModel is a CHECK on the whole row, e.g. "ends_at > starts_at"
+type CheckConstraintModel struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+}
@@ -0,0 +1,9 @@
+package models
+
+type DeleteModel struct {
+	TableName string            `json:"table"`
+	Filters   []FilterGroup     `json:"filters"`
+	Where     *FilterExpression `json:"where"`
+	Returning bool              `json:"returning"` // return the deleted rows in the response
+	AllowAll  bool              `json:"allow_all"` // required to delete every row when no filters are given
+}
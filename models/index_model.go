@@ -0,0 +1,17 @@
+package models
+
+type IndexModel struct {
+	Name        string   `json:"name"`
+	TableName   string   `json:"table"`
+	Columns     []string `json:"columns"`               // column names, optionally followed by ASC or DESC
+	Expressions []string `json:"expressions,omitempty"` // indexed expressions like lower(email)
+	Unique      bool     `json:"unique"`
+	Where       string   `json:"where,omitempty"`      // the condition of a partial index
+	Origin      string   `json:"origin,omitempty"`     // c for CREATE INDEX, u for a UNIQUE constraint, pk for the primary key
+	Sql         string   `json:"sql,omitempty"`        // empty for the automatic indexes of constraints
+	SizeBytes   *int64   `json:"size_bytes,omitempty"` // approximate size on disk, only reported when SQLite has dbstat
+}
+
+type DropIndexModel struct {
+	Name string `json:"name"`
+}
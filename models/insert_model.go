@@ -1,7 +1,32 @@
 package models
 
 type InsertModel struct {
-	TableName string   `json:"table"`
-	Columns   []string `json:"columns"`
-	Values    []any    `json:"values"`
+	TableName       string           `json:"table"`
+	Columns         []string         `json:"columns"`
+	Values          []any            `json:"values"`
+	Rows            []any            `json:"rows"` // bulk insert, each row is a values array aligned to Columns or a column -> value object
+	ContinueOnError bool             `json:"continue_on_error"`
+	OnConflict      *OnConflictModel `json:"on_conflict"`
+}
+
+type OnConflictModel struct {
+	Columns []string `json:"columns"` // conflict target, must match a primary key or unique constraint
+	Action  string   `json:"action"`  // do_nothing, merge, update
+	Update  []string `json:"update"`  // columns to overwrite when the action is update
+}
+
+type InsertResult struct {
+	ID     any    `json:"id"`     // the key value, a column -> value object for composite keys
+	Status string `json:"status"` // inserted, updated, skipped, failed
+}
+
+type BulkInsertResult struct {
+	IDs     []any            `json:"ids"` // nil for the rows that failed
+	Results []InsertResult   `json:"results"`
+	Errors  []InsertRowError `json:"errors"`
+}
+
+type InsertRowError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
 }
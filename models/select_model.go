@@ -1,9 +1,43 @@
 package models
 
 type SelectModel struct {
-	TableName       string        `json:"table"`
-	SelectedColumns []string      `json:"columns"`
-	Filters         []FilterGroup `json:"filters"`
+	TableName       string                   `json:"table"`
+	SelectedColumns []string                 `json:"columns"` // plain columns or embedded relations like "author(name,email)" and "comments(*)"
+	Filters         []FilterGroup            `json:"filters"`
+	Where           *FilterExpression        `json:"where"` // combined with filters using AND
+	Order           []OrderModel             `json:"order"`
+	Limit           *int                     `json:"limit"`  // capped by MAX_SELECT_DOCUMENTS
+	Offset          *int                     `json:"offset"` // cannot be combined with cursor
+	Cursor          string                   `json:"cursor"` // next_cursor of the previous page for keyset pagination
+	Aggregates      []AggregateModel         `json:"aggregates"`
+	GroupBy         []string                 `json:"group_by"`
+	Buckets         []BucketModel            `json:"buckets"`   // grouped like group_by, e.g. the day of created_at
+	Having          *FilterExpression        `json:"having"`    // conditions on aggregate aliases, bucket aliases and group_by columns
+	Relations       map[string]RelationModel `json:"relations"` // options of the embedded relations, keyed by the embed name
+}
+
+// RelationModel holds the filters and limits of a relation embedded with columns like "author(name,email)"
+type RelationModel struct {
+	Filters   []FilterGroup            `json:"filters"`
+	Where     *FilterExpression        `json:"where"`
+	Order     []OrderModel             `json:"order"`
+	Limit     *int                     `json:"limit"`
+	Relations map[string]RelationModel `json:"relations"` // options of the relations embedded inside this one
+}
+
+type AggregateModel struct {
+	Function string `json:"function"` // count, sum, avg, min, max
+	Column   string `json:"column"`   // "*" is only allowed with count
+	Alias    string `json:"alias"`    // defaults to function_column, or just the function for count(*)
+	Distinct bool   `json:"distinct"`
+}
+
+// BucketModel groups the rows of an aggregate select by a date part of a column, like "sum of amount by day"
+type BucketModel struct {
+	Column   string `json:"column"`
+	Function string `json:"function"`         // date or strftime
+	Format   string `json:"format,omitempty"` // strftime format like %Y-%m, only used by strftime
+	Alias    string `json:"alias"`            // defaults to function_column, used in columns, order and having
 }
 
 type FilterGroup struct {
@@ -12,6 +46,20 @@ type FilterGroup struct {
 }
 type FilterCondition struct {
 	Column   string `json:"column"`
-	Operator string `json:"operator"` // eq, ne, gt, lt, gte, lte, like, in, not_in
+	Operator string `json:"operator"` // eq, ne, gt, lt, gte, lte, like, ilike, in, not_in, between, starts_with, ends_with, contains, array_contains, regex, is_distinct_from
 	Value    any    `json:"value"`
+	Path     string `json:"path,omitempty"` // JSON path like $.address.city, compares json_extract(column, path) instead of the column
+}
+
+type OrderModel struct {
+	Column    string `json:"column"`
+	Direction string `json:"direction"` // asc, desc
+}
+
+// FilterExpression is a node of a boolean filter tree, either one of and/or/not or a condition leaf
+type FilterExpression struct {
+	And []FilterExpression `json:"and,omitempty"`
+	Or  []FilterExpression `json:"or,omitempty"`
+	Not *FilterExpression  `json:"not,omitempty"`
+	FilterCondition
 }
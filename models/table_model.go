@@ -5,9 +5,14 @@ type TablesModel struct {
 }
 
 type TableModel struct {
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	Sql          string        `json:"sql"`
-	Columns      []ColumnModel `json:"columns"`
-	RecordsCount int           `json:"records_count"`
+	Name         string                  `json:"name"`
+	Description  string                  `json:"description"`
+	Sql          string                  `json:"sql"`
+	KeyStrategy  string                  `json:"key_strategy"` // uuid, uuid_v7, ulid, autoincrement, client or composite
+	Columns      []ColumnModel           `json:"columns"`
+	Indexes      []IndexModel            `json:"indexes"`
+	Uniques      []UniqueConstraintModel `json:"unique_constraints,omitempty"`
+	Checks       []CheckConstraintModel  `json:"checks,omitempty"`
+	RecordsCount int                     `json:"records_count"`
+	CreatedAt    string                  `json:"created_at,omitempty"`
 }
@@ -0,0 +1,26 @@
+package models
+
+// DropTableModel drops a table, confirm has to repeat the table name
+type DropTableModel struct {
+	TableName string `json:"table"`
+	Confirm   string `json:"confirm"`
+}
+
+type RenameTableModel struct {
+	TableName string `json:"table"`
+	NewName   string `json:"new_name"`
+}
+
+// TruncateTableModel deletes every row of a table, confirm has to repeat the table name
+type TruncateTableModel struct {
+	TableName          string `json:"table"`
+	Confirm            string `json:"confirm"`
+	ResetAutoIncrement bool   `json:"reset_autoincrement"` // start AUTOINCREMENT keys from 1 again
+}
+
+// DuplicateTableModel copies the schema and indexes of a table, with_data copies the rows as well
+type DuplicateTableModel struct {
+	TableName string `json:"table"`
+	NewName   string `json:"new_name"`
+	WithData  bool   `json:"with_data"`
+}
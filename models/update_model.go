@@ -0,0 +1,19 @@
+package models
+
+type UpdateModel struct {
+	TableName string                      `json:"table"`
+	Values    map[string]any              `json:"values"`
+	Increment map[string]any              `json:"increment"` // column -> number added to the current value, negative to decrement
+	JSON      map[string][]JSONPatchModel `json:"json"`      // json column -> patch operations applied in order
+	Filters   []FilterGroup               `json:"filters"`
+	Where     *FilterExpression           `json:"where"`
+	AllowAll  bool                        `json:"allow_all"` // required to update every row when no filters are given
+}
+
+// JSONPatchModel changes part of a json column, Op is set, remove or append. Path is a JSON path like $.tags,
+// append adds Value to the end of the array at Path
+type JSONPatchModel struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}